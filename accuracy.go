@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// snapshotTTL is how long a forecast snapshot is kept. It must outlast the
+// 15-day forecast it holds, with room left to compare against actuals.
+const snapshotTTL = 90 * 24 * time.Hour
+
+// actualsTTL is how long observed history is cached for accuracy checks;
+// past days don't change once Visual Crossing has finalised them.
+const actualsTTL = 7 * 24 * time.Hour
+
+// ForecastSnapshot is what POST /weather/:city/snapshot stores: the
+// forecast as served at TakenAt.
+type ForecastSnapshot struct {
+	Location string        `json:"location"`
+	TakenAt  time.Time     `json:"takenAt"`
+	Days     []ForecastDay `json:"days"`
+}
+
+// AccuracyDay compares one forecast day with what was observed. LeadDays
+// is how far ahead of the day the snapshot was taken.
+type AccuracyDay struct {
+	Date          string   `json:"date"`
+	LeadDays      int      `json:"leadDays"`
+	PredictedTemp *float64 `json:"predictedTemp"`
+	ActualTemp    *float64 `json:"actualTemp"`
+	Error         *float64 `json:"error"`
+}
+
+// AccuracyReport is the body of GET /weather/:city/accuracy. The error
+// metrics cover only days with both a prediction and an observation, and
+// are null when there are none.
+type AccuracyReport struct {
+	Location     string        `json:"location"`
+	SnapshotDate string        `json:"snapshotDate"`
+	ComparedDays int           `json:"comparedDays"`
+	MAETemp      *float64      `json:"maeTemp"`
+	MAETempMax   *float64      `json:"maeTempMax"`
+	MAETempMin   *float64      `json:"maeTempMin"`
+	BiasTemp     *float64      `json:"biasTemp"`
+	Days         []AccuracyDay `json:"days"`
+}
+
+func snapshotKey(city, date string) string {
+	return cacheKey(city, "snapshot", date)
+}
+
+// postSnapshot stores today's (UTC) snapshot of the forecast for a city,
+// replacing any earlier one from the same day.
+func postSnapshot(c *gin.Context) {
+	ctx := c.Request.Context()
+	city := c.Param("city")
+	forecast, err := dailyForecast(ctx, weatherQuery{Location: city})
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+
+	snap := ForecastSnapshot{Location: forecast.Location, TakenAt: time.Now().UTC(), Days: forecast.Days}
+	value, _ := json.Marshal(snap)
+	date := snap.TakenAt.Format("2006-01-02")
+	if err := redisSet(ctx, snapshotKey(city, date), value, snapshotTTL); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to store snapshot"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"location": snap.Location, "date": date, "days": len(snap.Days)})
+}
+
+// getAccuracy compares the snapshot taken on ?date= with observed history
+// for every forecast day that has since passed.
+func getAccuracy(c *gin.Context) {
+	date, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a date in YYYY-MM-DD format"})
+		return
+	}
+	precision, err := parsePrecision(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	city := c.Param("city")
+	dateStr := date.Format("2006-01-02")
+	snap, err := loadSnapshot(ctx, city, dateStr)
+	if errors.Is(err, errNoSnapshot) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no snapshot for " + city + " on " + dateStr})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to load snapshot"})
+		return
+	}
+
+	// Only days before today (UTC) have complete observations
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	var past []ForecastDay
+	for _, d := range snap.Days {
+		if d.Datetime <= yesterday {
+			past = append(past, d)
+		}
+	}
+	if len(past) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "none of the forecast days in this snapshot have passed yet"})
+		return
+	}
+
+	actuals, err := fetchActuals(ctx, city, past[0].Datetime, past[len(past)-1].Datetime)
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+
+	report := compareForecast(past, actuals, date)
+	report.Location = snap.Location
+	report.SnapshotDate = dateStr
+	roundFloats(&report, precision)
+	respond(c, http.StatusOK, report)
+}
+
+var errNoSnapshot = errors.New("no snapshot")
+
+func loadSnapshot(ctx context.Context, city, date string) (*ForecastSnapshot, error) {
+	raw, err := redisGet(ctx, snapshotKey(city, date))
+	if err != nil {
+		return nil, err
+	}
+	if raw == "" {
+		return nil, errNoSnapshot
+	}
+	var snap ForecastSnapshot
+	if err := json.Unmarshal([]byte(raw), &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// fetchActuals returns the observed day for each date in [start, end],
+// keyed by date.
+func fetchActuals(ctx context.Context, city, start, end string) (map[string]ForecastDay, error) {
+	entry, err := fetchLocation(ctx, weatherQuery{
+		Location: city,
+		Start:    start,
+		End:      end,
+		Include:  "days",
+		Elements: "datetime,tempmax,tempmin,temp",
+		TTL:      actualsTTL,
+	}, cacheKey(city, "actuals", start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Days []ForecastDay `json:"days"`
+	}
+	if err := json.Unmarshal(entry.Data, &payload); err != nil {
+		return nil, err
+	}
+	actuals := make(map[string]ForecastDay, len(payload.Days))
+	for _, d := range payload.Days {
+		actuals[d.Datetime] = d
+	}
+	return actuals, nil
+}
+
+// compareForecast scores predicted days against actuals. Error is
+// predicted minus actual, so a positive bias means the forecast ran warm.
+func compareForecast(predicted []ForecastDay, actuals map[string]ForecastDay, takenOn time.Time) AccuracyReport {
+	var temp, tempMax, tempMin errorSum
+	report := AccuracyReport{Days: make([]AccuracyDay, 0, len(predicted))}
+	for _, p := range predicted {
+		a := actuals[p.Datetime]
+		day := AccuracyDay{Date: p.Datetime, PredictedTemp: p.Temp, ActualTemp: a.Temp}
+		if d, err := time.Parse("2006-01-02", p.Datetime); err == nil {
+			day.LeadDays = int(d.Sub(takenOn).Hours() / 24)
+		}
+		day.Error = temp.add(p.Temp, a.Temp)
+		tempMax.add(p.TempMax, a.TempMax)
+		tempMin.add(p.TempMin, a.TempMin)
+		if day.Error != nil {
+			report.ComparedDays++
+		}
+		report.Days = append(report.Days, day)
+	}
+
+	report.MAETemp, report.BiasTemp = temp.mae(), temp.bias()
+	report.MAETempMax, report.MAETempMin = tempMax.mae(), tempMin.mae()
+	return report
+}
+
+// errorSum accumulates forecast errors for one variable.
+type errorSum struct {
+	abs, signed float64
+	n           int
+}
+
+// add records predicted-actual when both are known and returns it.
+func (s *errorSum) add(predicted, actual *float64) *float64 {
+	if predicted == nil || actual == nil {
+		return nil
+	}
+	e := *predicted - *actual
+	s.abs += math.Abs(e)
+	s.signed += e
+	s.n++
+	return &e
+}
+
+func (s *errorSum) mae() *float64 {
+	if s.n == 0 {
+		return nil
+	}
+	v := s.abs / float64(s.n)
+	return &v
+}
+
+func (s *errorSum) bias() *float64 {
+	if s.n == 0 {
+		return nil
+	}
+	v := s.signed / float64(s.n)
+	return &v
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminAuth guards the /admin routes. Callers must send the configured
+// ADMIN_TOKEN as a bearer token, or in X-Admin-Token when Authorization is
+// taken by metrics basic auth. With no token configured the routes are off.
+func adminAuth(c *gin.Context) {
+	if adminToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin endpoints are disabled"})
+		return
+	}
+
+	token := c.GetHeader("X-Admin-Token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+		return
+	}
+	c.Next()
+}
+
+// globEscape escapes the characters SCAN MATCH treats specially, so s only
+// matches itself.
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// flushCache deletes every cached key starting with ?prefix= (inside the
+// configured CACHE_KEY_PREFIX and current schema version namespace), one DEL
+// per SCAN page. The prefix is matched literally, and an empty one is refused
+// so a typo can't wipe the whole database.
+func flushCache(c *gin.Context) {
+	prefix := c.Query("prefix")
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "prefix is required"})
+		return
+	}
+
+	deleted := 0
+	err := redisScan(c.Request.Context(), globEscape(cacheKey(prefix))+"*", func(keys []string) error {
+		args := make([]interface{}, 0, len(keys)+1)
+		args = append(args, "DEL")
+		for _, key := range keys {
+			args = append(args, key)
+		}
+
+		raw, err := redisCommand(c.Request.Context(), args...)
+		if err != nil {
+			return err
+		}
+		var n int
+		_ = json.Unmarshal(raw, &n)
+		deleted += n
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "cache flush failed", "deleted": deleted})
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// getCacheTTL reports the TTL applied to new cache writes.
+func getCacheTTL(c *gin.Context) {
+	respond(c, http.StatusOK, gin.H{"ttl": time.Duration(defaultCacheTTL.Load()).String()})
+}
+
+// putCacheTTL changes the TTL for cache writes from now on; entries already
+// stored keep the TTL they were written with. The value lives in memory, so
+// it applies to this instance only and resets on restart.
+func putCacheTTL(c *gin.Context) {
+	var req struct {
+		TTL string `json:"ttl"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	ttl, err := time.ParseDuration(req.TTL)
+	if err != nil || ttl < minCacheTTL || ttl > maxCacheTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ttl must be a Go duration between 1m and 168h, e.g. 6h"})
+		return
+	}
+
+	previous := time.Duration(defaultCacheTTL.Swap(int64(ttl)))
+	log.Printf("cache TTL changed from %s to %s", previous, ttl)
+	respond(c, http.StatusOK, gin.H{"ttl": ttl.String(), "previous": previous.String()})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlobEscape(t *testing.T) {
+	tests := map[string]string{
+		"london":    "london",
+		"*":         `\*`,
+		"a?b":       `a\?b`,
+		"[ab]":      `\[ab\]`,
+		`back\len`:  `back\\len`,
+		"são paulo": "são paulo",
+	}
+	for in, want := range tests {
+		if got := globEscape(in); got != want {
+			t.Errorf("globEscape(%q) = %q, want %q", in, got, want)
+		}
+		if !globPattern(globEscape(in)).MatchString(in) {
+			t.Errorf("escaped %q does not match itself", in)
+		}
+	}
+}
+
+func TestFlushCacheRemovesOnlyMatchingKeys(t *testing.T) {
+	f := useFakeUpstash(t)
+	withKeyPrefix(t, "test:")
+	withAdminToken(t, "secret")
+	for _, city := range []string{"london", "londonderry", "paris", "l*n"} {
+		f.set(cacheKey(city), "{}", time.Hour)
+	}
+	f.set("other-app:london", "{}", 0)
+	r := testRouter(t)
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	remaining := func() string {
+		keys := f.keys()
+		sort.Strings(keys)
+		return strings.Join(keys, " ")
+	}
+
+	// A wildcard is taken literally and matches nothing
+	w := serve(r, "DELETE", "/admin/cache?prefix=*", admin)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"deleted":0`) {
+		t.Errorf("prefix=* answered %d %s, want 0 deleted", w.Code, w.Body)
+	}
+
+	w = serve(r, "DELETE", "/admin/cache?prefix=lon", admin)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"deleted":2`) {
+		t.Errorf("prefix=lon answered %d %s, want 2 deleted", w.Code, w.Body)
+	}
+	if want := "other-app:london test:v1:l*n test:v1:paris"; remaining() != want {
+		t.Errorf("keys left = %q, want %q", remaining(), want)
+	}
+
+	w = serve(r, "DELETE", "/admin/cache?prefix=l*", admin)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"deleted":1`) {
+		t.Errorf("prefix=l* answered %d %s, want 1 deleted", w.Code, w.Body)
+	}
+	if want := "other-app:london test:v1:paris"; remaining() != want {
+		t.Errorf("keys left = %q, want %q", remaining(), want)
+	}
+}
+
+func withCacheTTL(t *testing.T, ttl time.Duration) {
+	old := defaultCacheTTL.Swap(int64(ttl))
+	t.Cleanup(func() { defaultCacheTTL.Store(old) })
+}
+
+func TestCacheTTLAppliesToNewWrites(t *testing.T) {
+	f := useFakeUpstash(t)
+	withAdminToken(t, "secret")
+	withCacheTTL(t, 12*time.Hour)
+	fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	putTTL := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/admin/config/cache-ttl", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expiresIn := func(key string) time.Duration {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return time.Until(f.expires[key])
+	}
+
+	w := serve(r, "GET", "/admin/config/cache-ttl", http.Header{"Authorization": {"Bearer secret"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"ttl":"12h0m0s"`) {
+		t.Fatalf("GET answered %d %s, want ttl 12h", w.Code, w.Body)
+	}
+
+	serve(r, "GET", "/weather/London", nil)
+	if d := expiresIn(cacheKey("London")); d < 12*time.Hour-time.Minute || d > 12*time.Hour {
+		t.Errorf("London expires in %s, want about 12h", d)
+	}
+
+	for _, body := range []string{`{"ttl":"30s"}`, `{"ttl":"169h"}`, `{"ttl":"soon"}`, `{}`} {
+		if w := putTTL(body); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: status = %d, want 400", body, w.Code)
+		}
+	}
+	if got := time.Duration(defaultCacheTTL.Load()); got != 12*time.Hour {
+		t.Fatalf("rejected PUTs changed the TTL to %s", got)
+	}
+
+	w = putTTL(`{"ttl":"2h"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"previous":"12h0m0s"`) {
+		t.Fatalf("PUT answered %d %s", w.Code, w.Body)
+	}
+
+	serve(r, "GET", "/weather/Paris", nil)
+	if d := expiresIn(cacheKey("Paris")); d < 2*time.Hour-time.Minute || d > 2*time.Hour {
+		t.Errorf("Paris expires in %s, want about 2h", d)
+	}
+	if d := expiresIn(cacheKey("London")); d < 11*time.Hour {
+		t.Errorf("London expires in %s; existing entries should keep their TTL", d)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Alert is one operational event worth paging someone about.
+type Alert struct {
+	Event   string    `json:"event"` // stable identifier, e.g. "quota_exhausted"
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Notifier delivers alerts to an operator channel.
+type Notifier interface {
+	Notify(ctx context.Context, a Alert) error
+}
+
+// webhookNotifier POSTs the Alert as JSON to any endpoint.
+type webhookNotifier struct{ url string }
+
+func (n webhookNotifier) Notify(ctx context.Context, a Alert) error {
+	body, _ := json.Marshal(a)
+	return postAlert(ctx, n.url, body)
+}
+
+// slackNotifier posts to a Slack incoming webhook, which wants {"text": ...}.
+type slackNotifier struct{ url string }
+
+func (n slackNotifier) Notify(ctx context.Context, a Alert) error {
+	body, _ := json.Marshal(map[string]string{"text": fmt.Sprintf("[weather-API] %s: %s", a.Event, a.Message)})
+	return postAlert(ctx, n.url, body)
+}
+
+func postAlert(ctx context.Context, rawURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// notifier is nil unless ALERT_WEBHOOK_URL is set, making alerts a no-op.
+var notifier Notifier
+
+// alertInterval is the minimum time between two alerts for the same event
+// (ALERT_MIN_INTERVAL, default 15m), so a flapping dependency can't flood
+// the channel.
+var alertInterval = 15 * time.Minute
+
+var lastAlert = struct {
+	sync.Mutex
+	at map[string]time.Time
+}{at: map[string]time.Time{}}
+
+// setupAlerts picks the notifier for ALERT_WEBHOOK_URL. ALERT_WEBHOOK_KIND
+// is "slack" or "webhook"; left empty, Slack URLs get the Slack format and
+// anything else the generic JSON one.
+func setupAlerts() {
+	rawURL := loadSecret("ALERT_WEBHOOK_URL")
+	if rawURL == "" {
+		return
+	}
+	alertInterval = envDuration("ALERT_MIN_INTERVAL", alertInterval)
+
+	kind := os.Getenv("ALERT_WEBHOOK_KIND")
+	if kind == "" {
+		kind = "webhook"
+		if u, err := url.Parse(rawURL); err == nil && u.Host == "hooks.slack.com" {
+			kind = "slack"
+		}
+	}
+	switch kind {
+	case "slack":
+		notifier = slackNotifier{rawURL}
+	case "webhook":
+		notifier = webhookNotifier{rawURL}
+	default:
+		panic("Unknown ALERT_WEBHOOK_KIND: " + kind)
+	}
+}
+
+// alert sends an alert in the background unless the same event was sent
+// within alertInterval. Delivery failures are only logged.
+func alert(event, format string, args ...interface{}) {
+	if notifier == nil {
+		return
+	}
+
+	now := time.Now()
+	lastAlert.Lock()
+	if last, ok := lastAlert.at[event]; ok && now.Sub(last) < alertInterval {
+		lastAlert.Unlock()
+		return
+	}
+	lastAlert.at[event] = now
+	lastAlert.Unlock()
+
+	a := Alert{Event: event, Message: fmt.Sprintf(format, args...), Time: now.UTC()}
+	n := notifier
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := n.Notify(ctx, a); err != nil {
+			log.Printf("failed to send %s alert: %v", a.Event, err)
+		}
+	}()
+}
+
+// redisOutageAfter is how long Redis must keep failing before it counts as
+// an outage worth alerting on (REDIS_OUTAGE_ALERT_AFTER, default 1m).
+var redisOutageAfter = time.Minute
+
+var redisHealth struct {
+	failingSince atomic.Int64 // Unix nanos of the first failure in a row, 0 when healthy
+	alerted      atomic.Bool
+}
+
+// recordRedisResult tracks consecutive Upstash failures, alerting once
+// they have lasted redisOutageAfter and again when Redis recovers.
+func recordRedisResult(err error) {
+	if err == nil {
+		if redisHealth.failingSince.Swap(0) != 0 && redisHealth.alerted.Swap(false) {
+			alert("redis_recovered", "Upstash Redis is answering again")
+		}
+		return
+	}
+
+	now := time.Now().UnixNano()
+	redisHealth.failingSince.CompareAndSwap(0, now)
+	since := time.Duration(now - redisHealth.failingSince.Load())
+	if since >= redisOutageAfter && !redisHealth.alerted.Swap(true) {
+		alert("redis_outage", "Upstash Redis has been failing for %s, serving without cache: %v", since.Truncate(time.Second), err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingNotifier keeps every alert it is sent.
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent []Alert
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, a Alert) error {
+	n.mu.Lock()
+	n.sent = append(n.sent, a)
+	n.mu.Unlock()
+	return nil
+}
+
+// withRecordingNotifier installs a recordingNotifier with an empty
+// rate-limit history.
+func withRecordingNotifier(t *testing.T) *recordingNotifier {
+	n := &recordingNotifier{}
+	old := notifier
+	notifier = n
+	lastAlert.Lock()
+	lastAlert.at = map[string]time.Time{}
+	lastAlert.Unlock()
+	t.Cleanup(func() { notifier = old })
+	return n
+}
+
+// of returns the alerts sent so far for event.
+func (n *recordingNotifier) of(event string) []Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var alerts []Alert
+	for _, a := range n.sent {
+		if a.Event == event {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
+
+// expect waits up to a second for an alert for event, which are delivered
+// in the background.
+func (n *recordingNotifier) expect(t *testing.T, event string) Alert {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if alerts := n.of(event); len(alerts) > 0 {
+			return alerts[0]
+		}
+	}
+	t.Fatalf("no %s alert sent", event)
+	return Alert{}
+}
+
+// count waits briefly for background deliveries, then counts event's alerts.
+func (n *recordingNotifier) count(event string) int {
+	time.Sleep(50 * time.Millisecond)
+	return len(n.of(event))
+}
+
+func TestAlertsAreRateLimited(t *testing.T) {
+	n := withRecordingNotifier(t)
+	alert("quota_warning", "first")
+	alert("quota_warning", "second")
+	alert("quota_exhausted", "other event")
+
+	if a := n.expect(t, "quota_warning"); a.Message != "first" {
+		t.Errorf("message = %q, want first", a.Message)
+	}
+	n.expect(t, "quota_exhausted")
+	if got := n.count("quota_warning"); got != 1 {
+		t.Errorf("quota_warning sent %d times, want once", got)
+	}
+}
+
+func withRedisOutageAfter(t *testing.T, d time.Duration) {
+	old := redisOutageAfter
+	redisOutageAfter = d
+	redisHealth.failingSince.Store(0)
+	redisHealth.alerted.Store(false)
+	t.Cleanup(func() {
+		redisOutageAfter = old
+		redisHealth.failingSince.Store(0)
+		redisHealth.alerted.Store(false)
+	})
+}
+
+func TestCanceledRedisRequestsDontCountAsOutage(t *testing.T) {
+	n := withRecordingNotifier(t)
+	withRedisOutageAfter(t, 0)
+	useFakeUpstash(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := redisGet(ctx, "anything"); err == nil {
+		t.Fatal("redisGet with a canceled context succeeded")
+	}
+	if since := redisHealth.failingSince.Load(); since != 0 {
+		t.Error("a canceled request was recorded as a Redis failure")
+	}
+	if got := n.count("redis_outage"); got != 0 {
+		t.Errorf("redis_outage sent %d times, want none", got)
+	}
+}
+
+func TestRedisOutageAlert(t *testing.T) {
+	n := withRecordingNotifier(t)
+	withRedisOutageAfter(t, 0)
+	useFakeUpstash(t)
+	redisClient = &http.Client{Transport: handlerTransport{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	})}}
+
+	redisGet(context.Background(), "anything")
+	n.expect(t, "redis_outage")
+
+	useFakeUpstash(t)
+	redisGet(context.Background(), "anything")
+	n.expect(t, "redis_recovered")
+}
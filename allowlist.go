@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cityAllowlist restricts which cities may be queried (CITY_ALLOWLIST,
+// comma-separated, case-insensitive). Nil means every city is allowed.
+var cityAllowlist map[string]bool
+
+func setupCityAllowlist() {
+	for _, city := range strings.Split(os.Getenv("CITY_ALLOWLIST"), ",") {
+		if city = normalizeCity(city); city == "" {
+			continue
+		}
+		if cityAllowlist == nil {
+			cityAllowlist = map[string]bool{}
+		}
+		cityAllowlist[city] = true
+	}
+}
+
+// normalizeCity lower-cases city and collapses its whitespace, so
+// " New  York" matches "new york".
+func normalizeCity(city string) string {
+	return strings.ToLower(strings.Join(strings.Fields(city), " "))
+}
+
+func cityAllowed(city string) bool {
+	return cityAllowlist == nil || cityAllowlist[normalizeCity(city)]
+}
+
+// cityAllowlistGuard answers 403 for a :city param or ?city= query that
+// isn't allowlisted, before anything is fetched. Postal code lookups can
+// resolve to any city, so they are refused outright while an allowlist is
+// set; POST bodies are checked by their handlers.
+func cityAllowlistGuard(c *gin.Context) {
+	if cityAllowlist == nil {
+		c.Next()
+		return
+	}
+	if c.FullPath() == "/weather/zip/:code" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "postal code lookups are disabled on this deployment"})
+		return
+	}
+	for _, city := range []string{c.Param("city"), c.Query("city")} {
+		if city != "" && !cityAllowed(city) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "city is not available on this deployment"})
+			return
+		}
+	}
+	c.Next()
+}
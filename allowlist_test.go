@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// withCityAllowlist sets CITY_ALLOWLIST for one test.
+func withCityAllowlist(t *testing.T, list string) {
+	old := cityAllowlist
+	cityAllowlist = nil
+	t.Setenv("CITY_ALLOWLIST", list)
+	setupCityAllowlist()
+	t.Cleanup(func() { cityAllowlist = old })
+}
+
+func TestCityAllowlist(t *testing.T) {
+	useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(londonPayload))
+	withCityAllowlist(t, " London , new  york,")
+	r := testRouter(t)
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"allowed", "/weather/London", http.StatusOK},
+		{"allowed, other case", "/weather/LONDON/forecast", http.StatusOK},
+		{"allowed, extra whitespace", "/weather/New%20%20York", http.StatusOK},
+		{"disallowed", "/weather/Paris", http.StatusForbidden},
+		{"disallowed subroute", "/weather/Paris/now", http.StatusForbidden},
+		{"disallowed validate", "/validate?city=Paris", http.StatusForbidden},
+		{"postal codes refused", "/weather/zip/10001", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := calls.Load()
+			w := serve(r, "GET", tt.target, nil)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusForbidden && calls.Load() != before {
+				t.Error("a refused city reached upstream")
+			}
+		})
+	}
+
+	w := serveJSON(r, "POST", "/weather/average", `{"cities":[{"city":"london"},{"city":"Paris"}]}`)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("average with a refused city: status = %d", w.Code)
+	}
+}
+
+func TestCityAllowlistUnsetAllowsEverything(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(londonPayload))
+	withCityAllowlist(t, "")
+	r := testRouter(t)
+
+	if cityAllowlist != nil {
+		t.Fatalf("allowlist = %v, want nil", cityAllowlist)
+	}
+	for _, target := range []string{"/weather/Paris", "/weather/zip/10001"} {
+		if w := serve(r, "GET", target, nil); w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", target, w.Code)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// normalsTTL is how long a month of historical normals stays cached. They
+// are long-term averages, so refetching them more than monthly wastes quota.
+const normalsTTL = 30 * 24 * time.Hour
+
+// AnomalyDay compares one forecast day's mean temperature with its
+// historical normal. Normal and Anomaly are null when no normal is known.
+type AnomalyDay struct {
+	Date    string   `json:"date"`
+	Actual  *float64 `json:"actual"`
+	Normal  *float64 `json:"normal"`
+	Anomaly *float64 `json:"anomaly"`
+}
+
+// anomalyDay is the part of a forecast day the anomaly endpoint reads.
+type anomalyDay struct {
+	Datetime string   `json:"datetime"`
+	Temp     *float64 `json:"temp"`
+}
+
+// statsNormal is Visual Crossing's include=stats "normal" block: each field
+// is a [min, mean, max] triple over the historical record.
+type statsNormal struct {
+	Temp    []float64 `json:"temp"`
+	TempMax []float64 `json:"tempmax"`
+	TempMin []float64 `json:"tempmin"`
+}
+
+// mean returns the normal mean temperature, falling back to the midpoint of
+// the normal max and min when the temp triple is missing.
+func (n *statsNormal) mean() *float64 {
+	if n == nil {
+		return nil
+	}
+	if len(n.Temp) == 3 {
+		return &n.Temp[1]
+	}
+	if len(n.TempMax) == 3 && len(n.TempMin) == 3 {
+		mid := (n.TempMax[1] + n.TempMin[1]) / 2
+		return &mid
+	}
+	return nil
+}
+
+func getAnomaly(c *gin.Context) {
+	precision, err := parsePrecision(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	city := c.Param("city")
+	entry, err := fetchWeather(ctx, weatherQuery{Location: city})
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+
+	var forecast struct {
+		ResolvedAddress string       `json:"resolvedAddress"`
+		Days            []anomalyDay `json:"days"`
+	}
+	if err := json.Unmarshal(entry.Data, &forecast); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "unexpected weather data"})
+		return
+	}
+
+	normals := map[string]*float64{}
+	available := true
+	for _, month := range forecastMonths(forecast.Days) {
+		monthNormals, err := fetchMonthNormals(ctx, city, month)
+		if err != nil {
+			// Degrade to forecast-only for this month rather than failing
+			available = false
+			continue
+		}
+		for date, normal := range monthNormals {
+			normals[date] = normal
+		}
+	}
+
+	days := make([]AnomalyDay, 0, len(forecast.Days))
+	for _, d := range forecast.Days {
+		day := AnomalyDay{Date: d.Datetime, Actual: d.Temp, Normal: normals[monthDay(d.Datetime)]}
+		if day.Actual != nil && day.Normal != nil {
+			delta := *day.Actual - *day.Normal
+			day.Anomaly = &delta
+		}
+		days = append(days, day)
+	}
+
+	resp := gin.H{
+		"location":         forecast.ResolvedAddress,
+		"normalsAvailable": available && len(normals) > 0,
+		"days":             days,
+	}
+	roundFloats(&days, precision)
+	setDataAge(c, entry.FetchedAt)
+	respond(c, http.StatusOK, resp)
+}
+
+// forecastMonths returns the first day of each distinct month the forecast
+// covers, in order.
+func forecastMonths(days []anomalyDay) []time.Time {
+	var months []time.Time
+	for _, d := range days {
+		t, err := time.Parse("2006-01-02", d.Datetime)
+		if err != nil {
+			continue
+		}
+		month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if len(months) == 0 || !months[len(months)-1].Equal(month) {
+			months = append(months, month)
+		}
+	}
+	return months
+}
+
+// monthDay turns "2006-01-02" into the "01-02" key normals are stored under.
+func monthDay(date string) string {
+	if len(date) != len("2006-01-02") {
+		return ""
+	}
+	return date[5:]
+}
+
+// fetchMonthNormals returns the normal mean temperature for each day of
+// month, keyed by "01-02". Normals don't depend on the year, so they are
+// cached per calendar month for normalsTTL.
+func fetchMonthNormals(ctx context.Context, city string, month time.Time) (map[string]*float64, error) {
+	start := month.Format("2006-01-02")
+	end := month.AddDate(0, 1, -1).Format("2006-01-02")
+
+	entry, err := fetchLocation(ctx, weatherQuery{
+		Location: city,
+		Start:    start,
+		End:      end,
+		Include:  "days,stats",
+		TTL:      normalsTTL,
+	}, cacheKey(city, "normals", month.Format("01")))
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Days []struct {
+			Datetime string       `json:"datetime"`
+			Normal   *statsNormal `json:"normal"`
+		} `json:"days"`
+	}
+	if err := json.Unmarshal(entry.Data, &payload); err != nil {
+		return nil, err
+	}
+
+	normals := map[string]*float64{}
+	for _, d := range payload.Days {
+		if mean := d.Normal.mean(); mean != nil {
+			normals[monthDay(d.Datetime)] = mean
+		}
+	}
+	return normals, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheAuditReport summarises one pass over the cached keys.
+type CacheAuditReport struct {
+	StartedAt      time.Time `json:"startedAt"`
+	Duration       string    `json:"duration"`
+	TotalKeys      int       `json:"totalKeys"`
+	KeysWithoutTTL int       `json:"keysWithoutTtl"`
+	DeletedKeys    int       `json:"deletedKeys"`
+	EstimatedBytes int64     `json:"estimatedBytes"`
+}
+
+// errAuditNoPrefix refuses to delete keys when CACHE_KEY_PREFIX is empty:
+// the scan would then cover the whole database, including keys other apps
+// keep without a TTL.
+var errAuditNoPrefix = errors.New("CACHE_AUDIT_DELETE_PERSISTENT needs a CACHE_KEY_PREFIX")
+
+// auditMu keeps the scheduled job and the admin trigger from overlapping.
+var auditMu sync.Mutex
+
+// startCacheAudit launches the background audit when CACHE_AUDIT_INTERVAL is
+// set (e.g. "6h"). Keys that lost their TTL are only deleted when
+// CACHE_AUDIT_DELETE_PERSISTENT=true, which also requires a CACHE_KEY_PREFIX;
+// otherwise the job just logs.
+func startCacheAudit() {
+	if auditDeletesPersistent() && cacheKeyPrefix == "" {
+		panic(errAuditNoPrefix.Error())
+	}
+
+	raw := os.Getenv("CACHE_AUDIT_INTERVAL")
+	if raw == "" {
+		return
+	}
+	interval, err := time.ParseDuration(raw)
+	if err != nil || interval <= 0 {
+		panic("Invalid CACHE_AUDIT_INTERVAL: " + raw)
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			if !auditMu.TryLock() {
+				continue
+			}
+			report, err := auditCache(context.Background(), auditDeletesPersistent())
+			auditMu.Unlock()
+			if err != nil {
+				log.Printf("cache audit failed: %v", err)
+				continue
+			}
+			logAuditReport(report)
+		}
+	}()
+}
+
+func runCacheAudit(c *gin.Context) {
+	if !auditMu.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "cache audit already running"})
+		return
+	}
+	defer auditMu.Unlock()
+
+	report, err := auditCache(c.Request.Context(), auditDeletesPersistent())
+	if errors.Is(err, errAuditNoPrefix) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "cache audit failed"})
+		return
+	}
+	logAuditReport(report)
+	c.JSON(http.StatusOK, report)
+}
+
+func auditDeletesPersistent() bool {
+	return os.Getenv("CACHE_AUDIT_DELETE_PERSISTENT") == "true"
+}
+
+// auditCache scans every key under the cache prefix, checking TTL and memory
+// usage in pipelined batches so it costs one request per SCAN page.
+func auditCache(ctx context.Context, deletePersistent bool) (CacheAuditReport, error) {
+	report := CacheAuditReport{StartedAt: time.Now()}
+	if deletePersistent && cacheKeyPrefix == "" {
+		return report, errAuditNoPrefix
+	}
+
+	err := redisScan(ctx, globEscape(cacheKeyPrefix)+"*", func(keys []string) error {
+		cmds := make([][]interface{}, 0, 2*len(keys))
+		for _, key := range keys {
+			cmds = append(cmds, []interface{}{"TTL", key}, []interface{}{"MEMORY", "USAGE", key})
+		}
+		results, err := redisPipeline(ctx, cmds)
+		if err != nil {
+			return err
+		}
+
+		var persistent []interface{}
+		for i, key := range keys {
+			var ttl, size int64
+			_ = json.Unmarshal(results[2*i], &ttl)
+			_ = json.Unmarshal(results[2*i+1], &size)
+
+			report.TotalKeys++
+			report.EstimatedBytes += size
+			if ttl == -1 {
+				report.KeysWithoutTTL++
+				persistent = append(persistent, key)
+			}
+		}
+
+		if deletePersistent && len(persistent) > 0 {
+			if _, err := redisCommand(ctx, append([]interface{}{"DEL"}, persistent...)...); err != nil {
+				return err
+			}
+			report.DeletedKeys += len(persistent)
+		}
+		return nil
+	})
+
+	report.Duration = time.Since(report.StartedAt).String()
+	return report, err
+}
+
+func logAuditReport(r CacheAuditReport) {
+	log.Printf("cache audit: %d keys, %d without TTL, %d deleted, ~%d bytes, took %s",
+		r.TotalKeys, r.KeysWithoutTTL, r.DeletedKeys, r.EstimatedBytes, r.Duration)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestAuditDeletesOnlyPersistentPrefixedKeys(t *testing.T) {
+	f := useFakeUpstash(t)
+	withKeyPrefix(t, "test:")
+	f.set("test:v1:london", "{}", time.Hour)
+	f.set("test:v1:paris", "{}", 0)
+	f.set("other-app:session", "{}", 0)
+
+	report, err := auditCache(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TotalKeys != 2 || report.KeysWithoutTTL != 1 || report.DeletedKeys != 1 {
+		t.Errorf("report = %+v, want 2 keys, 1 without TTL, 1 deleted", report)
+	}
+
+	keys := f.keys()
+	sort.Strings(keys)
+	if want := []string{"other-app:session", "test:v1:london"}; len(keys) != 2 || keys[0] != want[0] || keys[1] != want[1] {
+		t.Errorf("keys left = %v, want %v", keys, want)
+	}
+}
+
+func TestAuditRefusesDeleteWithoutPrefix(t *testing.T) {
+	f := useFakeUpstash(t)
+	withKeyPrefix(t, "")
+	f.set("other-app:session", "{}", 0)
+
+	if _, err := auditCache(context.Background(), true); !errors.Is(err, errAuditNoPrefix) {
+		t.Fatalf("err = %v, want errAuditNoPrefix", err)
+	}
+	if _, ok := f.get("other-app:session"); !ok {
+		t.Error("audit deleted a key outside the cache")
+	}
+
+	// Reporting alone is still allowed
+	report, err := auditCache(context.Background(), false)
+	if err != nil || report.KeysWithoutTTL != 1 || report.DeletedKeys != 0 {
+		t.Errorf("report-only audit = %+v, %v", report, err)
+	}
+}
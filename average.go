@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAverageCities bounds how many lookups one average request can trigger.
+const maxAverageCities = 20
+
+type averageRequest struct {
+	Cities []struct {
+		City   string   `json:"city"`
+		Weight *float64 `json:"weight"` // defaults to 1
+	} `json:"cities"`
+}
+
+// AverageCity is one city's contribution; Weight is normalised over the
+// cities that were actually included.
+type AverageCity struct {
+	City     string  `json:"city"`
+	Location string  `json:"location"`
+	Temp     float64 `json:"temp"`
+	Weight   float64 `json:"weight"`
+	Source   string  `json:"source"`
+}
+
+// ExcludedCity is a requested city left out of the average.
+type ExcludedCity struct {
+	City   string `json:"city"`
+	Reason string `json:"reason"`
+}
+
+// AverageResponse is the body of POST /weather/average.
+type AverageResponse struct {
+	AverageTemp float64        `json:"averageTemp"`
+	Cities      []AverageCity  `json:"cities"`
+	Excluded    []ExcludedCity `json:"excluded"`
+}
+
+// postAverage returns the weighted average current temperature over a list
+// of cities, fetched concurrently through the normal cached path. A city
+// listed more than once is fetched once and counted at every position. Cities
+// that fail or report no temperature are excluded and listed as such.
+func postAverage(c *gin.Context) {
+	units, err := parseUnitOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req averageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if len(req.Cities) == 0 || len(req.Cities) > maxAverageCities {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "provide between 1 and 20 cities"})
+		return
+	}
+
+	weights := make([]float64, len(req.Cities))
+	for i, entry := range req.Cities {
+		if strings.TrimSpace(entry.City) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "city names must not be empty"})
+			return
+		}
+		if !cityAllowed(entry.City) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "city is not available on this deployment", "city": entry.City})
+			return
+		}
+		weights[i] = 1
+		if entry.Weight != nil {
+			if *entry.Weight < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "weights must not be negative"})
+				return
+			}
+			weights[i] = *entry.Weight
+		}
+	}
+
+	// Fetch each distinct city once, however often it was listed
+	names := make([]string, len(req.Cities))
+	for i, entry := range req.Cities {
+		names[i] = entry.City
+	}
+	unique, slot := dedupeCities(names)
+
+	fetched := make([]*CurrentResponse, len(unique))
+	fetchErrs := make([]error, len(unique))
+	var wg sync.WaitGroup
+	for i, city := range unique {
+		wg.Add(1)
+		go func(i int, city string) {
+			defer wg.Done()
+			fetched[i], fetchErrs[i] = currentConditions(c.Request.Context(), weatherQuery{Location: city})
+		}(i, city)
+	}
+	wg.Wait()
+
+	results := make([]*CurrentResponse, len(req.Cities))
+	errs := make([]error, len(req.Cities))
+	for i := range req.Cities {
+		results[i], errs[i] = fetched[slot[i]], fetchErrs[slot[i]]
+	}
+
+	resp := AverageResponse{Cities: []AverageCity{}, Excluded: []ExcludedCity{}}
+	var totalWeight float64
+	for i, entry := range req.Cities {
+		switch {
+		case errors.Is(errs[i], errLocationNotFound):
+			resp.Excluded = append(resp.Excluded, ExcludedCity{entry.City, "location not found"})
+		case errs[i] != nil:
+			resp.Excluded = append(resp.Excluded, ExcludedCity{entry.City, "failed to fetch weather data"})
+		case results[i].Current.Temp == nil:
+			resp.Excluded = append(resp.Excluded, ExcludedCity{entry.City, "no temperature reported"})
+		default:
+			// Duplicates share one result, so convert a copy
+			temp := *results[i].Current.Temp
+			convert(&temp, tempUnits[units.Temp])
+			resp.Cities = append(resp.Cities, AverageCity{
+				City:     entry.City,
+				Location: results[i].Location,
+				Temp:     temp,
+				Weight:   weights[i],
+				Source:   results[i].Source,
+			})
+			totalWeight += weights[i]
+		}
+	}
+
+	if len(resp.Cities) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no city could be fetched", "excluded": resp.Excluded})
+		return
+	}
+	if totalWeight == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weights of the fetched cities sum to zero", "excluded": resp.Excluded})
+		return
+	}
+
+	for i := range resp.Cities {
+		resp.Cities[i].Weight /= totalWeight
+		resp.AverageTemp += resp.Cities[i].Temp * resp.Cities[i].Weight
+	}
+	respond(c, http.StatusOK, resp)
+}
+
+// dedupeCities collapses names that normalizeCity treats as the same city,
+// i.e. that differ only in case or whitespace. It returns the distinct
+// names, in first-seen order and trimmed, and for each input its index into
+// that list.
+func dedupeCities(names []string) (unique []string, slot []int) {
+	seen := map[string]int{}
+	slot = make([]int, len(names))
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		key := normalizeCity(name)
+		j, ok := seen[key]
+		if !ok {
+			j = len(unique)
+			seen[key] = j
+			unique = append(unique, name)
+		}
+		slot[i] = j
+	}
+	return unique, slot
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDedupeCities(t *testing.T) {
+	unique, slot := dedupeCities([]string{"London", " paris", "LONDON ", "Tokyo", "Paris", "New  York", "new york"})
+	if want := []string{"London", "paris", "Tokyo", "New  York"}; !reflect.DeepEqual(unique, want) {
+		t.Errorf("unique = %q, want %q", unique, want)
+	}
+	if want := []int{0, 1, 0, 2, 1, 3, 3}; !reflect.DeepEqual(slot, want) {
+		t.Errorf("slot = %v, want %v", slot, want)
+	}
+}
+
+func TestAverageFetchesRepeatedCitiesOnce(t *testing.T) {
+	useFakeUpstash(t)
+	temps := map[string]float64{"london": 10, "paris": 20, "tokyo": 30}
+	calls := fakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		city, _ := url.PathUnescape(path.Base(r.URL.Path))
+		fmt.Fprintf(w, `{"resolvedAddress":%q,"days":[{}],"currentConditions":{"temp":%v}}`,
+			city, temps[strings.ToLower(strings.TrimSpace(city))])
+	})
+	r := testRouter(t)
+
+	w := serveJSON(r, "POST", "/weather/average", `{"cities":[
+		{"city":"London"}, {"city":"Paris"}, {"city":"london "}, {"city":"Tokyo"}, {"city":"PARIS"}
+	]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("upstream calls = %d, want one per distinct city", n)
+	}
+
+	var resp AverageResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, city := range resp.Cities {
+		got = append(got, fmt.Sprintf("%s=%v", city.City, city.Temp))
+	}
+	if want := []string{"London=10", "Paris=20", "london =10", "Tokyo=30", "PARIS=20"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cities = %q, want %q", got, want)
+	}
+	if resp.AverageTemp != 18 {
+		t.Errorf("averageTemp = %v, want 18", resp.AverageTemp)
+	}
+}
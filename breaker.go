@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errBreakerOpen means the circuit breaker is open and Visual Crossing is
+// not being called until the next probe.
+var errBreakerOpen = errors.New("upstream circuit breaker is open")
+
+// circuitBreaker stops calling Visual Crossing after threshold consecutive
+// failures. While open, one request per cooldown goes through as a probe;
+// a success closes the breaker and a failure keeps it open. A probe that
+// never reports back (e.g. its client went away) just waits for the next
+// one. nil means the breaker is off.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int // consecutive failures
+	open     bool
+	probeAt  time.Time // while open, when the next probe may go
+}
+
+var upstreamBreaker *circuitBreaker
+
+// setupBreaker enables the breaker when UPSTREAM_BREAKER_THRESHOLD
+// (consecutive failures) is set. It stays open for UPSTREAM_BREAKER_COOLDOWN
+// (default 30s) between probes.
+func setupBreaker() {
+	raw := os.Getenv("UPSTREAM_BREAKER_THRESHOLD")
+	if raw == "" {
+		return
+	}
+	threshold, err := strconv.Atoi(raw)
+	if err != nil || threshold < 1 {
+		panic("Invalid UPSTREAM_BREAKER_THRESHOLD: " + raw)
+	}
+	upstreamBreaker = &circuitBreaker{
+		threshold: threshold,
+		cooldown:  envDuration("UPSTREAM_BREAKER_COOLDOWN", 30*time.Second),
+	}
+}
+
+// allow returns errBreakerOpen unless the breaker is closed or this request
+// is the next probe.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return nil
+	}
+	now := time.Now()
+	if now.Before(b.probeAt) {
+		return errBreakerOpen
+	}
+	b.probeAt = now.Add(b.cooldown)
+	return nil
+}
+
+// retryAfter is how long until the next probe may go.
+func (b *circuitBreaker) retryAfter() time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if wait := time.Until(b.probeAt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// record reports the outcome of an upstream call, nil meaning Visual
+// Crossing answered. Opening and closing the breaker each raise an alert.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	if err == nil {
+		wasOpen := b.open
+		b.failures, b.open = 0, false
+		b.mu.Unlock()
+		if wasOpen {
+			alert("upstream_breaker_closed", "Visual Crossing is answering again, circuit breaker closed")
+		}
+		return
+	}
+
+	b.failures++
+	opened := !b.open && b.failures >= b.threshold
+	if opened || b.open {
+		b.open, b.probeAt = true, time.Now().Add(b.cooldown)
+	}
+	failures := b.failures
+	b.mu.Unlock()
+	if opened {
+		alert("upstream_breaker_open", "Visual Crossing failed %d times in a row, pausing upstream calls for %s: %v", failures, b.cooldown, err)
+	}
+}
+
+// BreakerStatus is the breaker's state as reported by /status.
+type BreakerStatus struct {
+	State     string     `json:"state"` // "closed", "open" or "disabled"
+	Failures  int        `json:"consecutiveFailures"`
+	NextProbe *time.Time `json:"nextProbe,omitempty"` // only while open
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	if b == nil {
+		return BreakerStatus{State: "disabled"}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := BreakerStatus{State: "closed", Failures: b.failures}
+	if b.open {
+		probeAt := b.probeAt.UTC()
+		s.State, s.NextProbe = "open", &probeAt
+	}
+	return s
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func withBreaker(t *testing.T, threshold int, cooldown time.Duration) *circuitBreaker {
+	old := upstreamBreaker
+	upstreamBreaker = &circuitBreaker{threshold: threshold, cooldown: cooldown}
+	t.Cleanup(func() { upstreamBreaker = old })
+	return upstreamBreaker
+}
+
+func serveUpstreamError(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func TestBreakerOpensAfterThresholdAndProbes(t *testing.T) {
+	withRecordingNotifier(t)
+	b := withBreaker(t, 3, time.Hour)
+	failure := errors.New("visual crossing returned 500")
+
+	b.record(failure)
+	b.record(nil) // a success resets the count
+	b.record(failure)
+	b.record(failure)
+	if err := b.allow(); err != nil {
+		t.Fatalf("open after 2 consecutive failures: %v", err)
+	}
+	b.record(failure)
+	if err := b.allow(); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("allow after 3 failures = %v, want errBreakerOpen", err)
+	}
+
+	// Once the cooldown is over exactly one probe goes through
+	b.mu.Lock()
+	b.probeAt = time.Now()
+	b.mu.Unlock()
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("second request during the probe = %v, want errBreakerOpen", err)
+	}
+
+	b.record(nil)
+	if err := b.allow(); err != nil {
+		t.Errorf("still open after a successful probe: %v", err)
+	}
+}
+
+func TestBreakerOpenSendsAlert(t *testing.T) {
+	n := withRecordingNotifier(t)
+	useFakeUpstash(t)
+	withBreaker(t, 2, time.Minute)
+	calls := fakeUpstream(t, serveUpstreamError)
+	r := testRouter(t)
+
+	for i, city := range []string{"London", "Paris"} {
+		if w := serve(r, "GET", "/weather/"+city, nil); w.Code != http.StatusBadGateway {
+			t.Fatalf("request %d: status = %d, want 502", i+1, w.Code)
+		}
+	}
+	a := n.expect(t, "upstream_breaker_open")
+	if a.Message == "" || a.Time.IsZero() {
+		t.Errorf("alert = %+v", a)
+	}
+
+	w := serve(r, "GET", "/weather/Berlin", nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status with breaker open = %d, want 503", w.Code)
+	}
+	if secs, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || secs < 1 || secs > 61 {
+		t.Errorf("Retry-After = %q, want the seconds until the next probe", w.Header().Get("Retry-After"))
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream calls = %d, want none once the breaker is open", n)
+	}
+
+	// Only one alert per opening
+	serve(r, "GET", "/weather/Rome", nil)
+	if got := n.count("upstream_breaker_open"); got != 1 {
+		t.Errorf("upstream_breaker_open sent %d times, want once", got)
+	}
+}
+
+func TestBreakerClosesOnSuccessfulProbe(t *testing.T) {
+	n := withRecordingNotifier(t)
+	useFakeUpstash(t)
+	b := withBreaker(t, 1, time.Minute)
+	failing := true
+	fakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			serveUpstreamError(w, r)
+			return
+		}
+		servePayload(londonPayload)(w, r)
+	})
+	r := testRouter(t)
+
+	serve(r, "GET", "/weather/London", nil)
+	n.expect(t, "upstream_breaker_open")
+
+	failing = false
+	b.mu.Lock()
+	b.probeAt = time.Now()
+	b.mu.Unlock()
+	if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusOK {
+		t.Fatalf("probe status = %d, want 200", w.Code)
+	}
+	n.expect(t, "upstream_breaker_closed")
+}
+
+func TestBreakerServesStaleCacheWhileOpen(t *testing.T) {
+	useFakeUpstash(t)
+	b := withBreaker(t, 1, time.Minute)
+	fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	serve(r, "GET", "/weather/London", nil)
+	cacheAged(t, cacheKey("London"), 2*time.Hour)
+	b.record(errors.New("visual crossing returned 503"))
+
+	if w := serve(r, "GET", "/weather/London?max_age=60", nil); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want the stale entry while the breaker is open", w.Code)
+	}
+}
+
+func TestNotFoundDoesNotTripBreaker(t *testing.T) {
+	useFakeUpstash(t)
+	withBreaker(t, 2, time.Minute)
+	calls := fakeUpstream(t, serveNotFound)
+	r := testRouter(t)
+
+	for _, city := range []string{"Atlantis", "Lemuria", "Mu"} {
+		if w := serve(r, "GET", "/weather/"+city, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", city, w.Code)
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("upstream calls = %d, want 3", n)
+	}
+}
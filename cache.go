@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheEntry is what we store in Redis for a weather payload: the raw
+// Visual Crossing bytes plus when we fetched them, so freshness can be
+// judged per request. NotFound entries are the negative-cache sentinel for
+// locations Visual Crossing doesn't know; they carry no data.
+type cacheEntry struct {
+	FetchedAt time.Time       `json:"fetchedAt"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	NotFound  bool            `json:"notFound,omitempty"`
+}
+
+// cacheSchemaVersion namespaces every cacheKey. Bump it whenever the bytes
+// stored in a cacheEntry change shape (the entry struct itself, or what is
+// requested from Visual Crossing): new deploys then miss on the old keys,
+// which age out by TTL, instead of misparsing them. Service state (stateKey)
+// is not versioned, so the daily usage count and maintenance flag survive a
+// bump. It is a var only so tests can simulate a bump.
+var cacheSchemaVersion = "v1"
+
+// errLocationNotFound means Visual Crossing rejected the location itself,
+// as opposed to failing to answer.
+var errLocationNotFound = errors.New("location not found")
+
+// negativeCacheTTL is how long a not-found result is cached
+// (NEGATIVE_CACHE_TTL, default 5m). Kept short so a location misreported as
+// unknown during an upstream glitch recovers quickly.
+var negativeCacheTTL = 5 * time.Minute
+
+// defaultCacheTTL is how long a fetch is cached when the query doesn't set
+// its own TTL, in nanoseconds. It is atomic because PUT
+// /admin/config/cache-ttl changes it while requests are reading it.
+var defaultCacheTTL atomic.Int64
+
+// Bounds for PUT /admin/config/cache-ttl.
+const (
+	minCacheTTL = time.Minute
+	maxCacheTTL = 7 * 24 * time.Hour
+)
+
+func init() {
+	defaultCacheTTL.Store(int64(12 * time.Hour))
+}
+
+// A not-found is only negative-cached once the same key has failed
+// negativeCacheAfter times (NEGATIVE_CACHE_AFTER, default 3) without a
+// success in between, within negativeCacheWindow of the first failure
+// (NEGATIVE_CACHE_WINDOW, default 10m). Raising the count keeps an isolated
+// upstream glitch from locking a real city out for negativeCacheTTL.
+var (
+	negativeCacheAfter  = 3
+	negativeCacheWindow = 10 * time.Minute
+)
+
+// orNotFound returns the entry, or errLocationNotFound for a negative one.
+func (e *cacheEntry) orNotFound() (*cacheEntry, error) {
+	if e.NotFound {
+		return nil, errLocationNotFound
+	}
+	return e, nil
+}
+
+// setDataAge sets X-Data-Age to how long ago the served data was fetched,
+// e.g. "3h12m5s", so clients can judge cached responses. Fresh fetches
+// report roughly zero.
+func setDataAge(c *gin.Context, fetchedAt time.Time) {
+	c.Header("X-Data-Age", time.Since(fetchedAt).Truncate(time.Second).String())
+}
+
+// minMaxAge is the smallest ?max_age= we honour. Anything lower would let a
+// client force an upstream fetch on every request.
+const minMaxAge = time.Minute
+
+// readCache returns the entry stored under key. Values written before
+// entries were timestamped don't decode into one and count as misses, so
+// they get replaced on the next fetch.
+func readCache(ctx context.Context, key string) (*cacheEntry, bool) {
+	raw, err := redisGet(ctx, key)
+	if err != nil || raw == "" {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil || (len(entry.Data) == 0 && !entry.NotFound) {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func writeCache(ctx context.Context, key string, entry *cacheEntry, ttl time.Duration) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return redisSet(ctx, key, value, ttl)
+}
+
+// newEntry wraps a payload fetched just now.
+func newEntry(body []byte) *cacheEntry {
+	return &cacheEntry{FetchedAt: time.Now().UTC(), Data: body}
+}
+
+func writeNotFound(ctx context.Context, key string) error {
+	value, _ := json.Marshal(cacheEntry{FetchedAt: time.Now().UTC(), NotFound: true})
+	return redisSet(ctx, key, value, negativeCacheTTL)
+}
+
+// recordNotFound counts a not-found for key and writes the negative entry
+// once negativeCacheAfter is reached. The counter lives in Redis so every
+// instance's failures add up; if it can't be read, nothing is cached.
+func recordNotFound(ctx context.Context, key string) error {
+	if negativeCacheAfter <= 1 {
+		return writeNotFound(ctx, key)
+	}
+
+	countKey := key + ":notfound"
+	results, err := redisPipeline(ctx, [][]interface{}{
+		{"SET", countKey, 0, "EX", int(negativeCacheWindow.Seconds()), "NX"},
+		{"INCR", countKey},
+	})
+	if err != nil {
+		return err
+	}
+	var n int
+	if err := json.Unmarshal(results[1], &n); err != nil {
+		return err
+	}
+	if n < negativeCacheAfter {
+		return nil
+	}
+	if err := writeNotFound(ctx, key); err != nil {
+		return err
+	}
+	_, err = redisCommand(ctx, "DEL", countKey)
+	return err
+}
+
+// clearNotFound resets key's not-found count after a successful fetch, so
+// only consecutive failures count toward negative caching.
+func clearNotFound(ctx context.Context, key string) {
+	if negativeCacheAfter > 1 {
+		_, _ = redisCommand(ctx, "DEL", key+":notfound")
+	}
+}
+
+// freshEnough reports whether entry satisfies the query's max age. A zero
+// MaxAge accepts anything still in the cache.
+func (q weatherQuery) freshEnough(entry *cacheEntry) bool {
+	return q.MaxAge == 0 || time.Since(entry.FetchedAt) <= q.MaxAge
+}
+
+// parseMaxAge reads ?max_age= (seconds). Values below minMaxAge are raised
+// to it; zero means no limit.
+func parseMaxAge(c *gin.Context) (time.Duration, error) {
+	raw := c.Query("max_age")
+	if raw == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return 0, errors.New("max_age must be a positive number of seconds")
+	}
+	if maxAge := time.Duration(seconds) * time.Second; maxAge > minMaxAge {
+		return maxAge, nil
+	}
+	return minMaxAge, nil
+}
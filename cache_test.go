@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const londonPayload = `{"resolvedAddress":"London, England, United Kingdom","days":[{}]}`
+
+// cacheAged stores london's payload as if it had been fetched age ago.
+func cacheAged(t *testing.T, key string, age time.Duration) {
+	entry := &cacheEntry{FetchedAt: time.Now().Add(-age).UTC(), Data: json.RawMessage(londonPayload)}
+	if err := writeCache(context.Background(), key, entry, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxAgeRefetchesOnlyOlderEntries(t *testing.T) {
+	tests := []struct {
+		name      string
+		age       time.Duration
+		query     string
+		wantFetch bool
+	}{
+		{"no max_age serves any age", 11 * time.Hour, "", false},
+		{"younger than max_age", 10 * time.Minute, "?max_age=3600", false},
+		{"older than max_age", 2 * time.Hour, "?max_age=3600", true},
+		{"tiny max_age raised to the minimum", 30 * time.Second, "?max_age=1", false},
+		{"older than the minimum", 90 * time.Second, "?max_age=1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeUpstash(t)
+			calls := fakeUpstream(t, servePayload(londonPayload))
+			r := testRouter(t)
+			key := cacheKey("London")
+			cacheAged(t, key, tt.age)
+
+			if w := serve(r, "GET", "/weather/London"+tt.query, nil); w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if fetched := calls.Load() == 1; fetched != tt.wantFetch {
+				t.Fatalf("fetched upstream = %v, want %v", fetched, tt.wantFetch)
+			}
+
+			entry, ok := readCache(context.Background(), key)
+			if !ok {
+				t.Fatal("entry missing from the cache")
+			}
+			if refreshed := time.Since(entry.FetchedAt) < 5*time.Second; refreshed != tt.wantFetch {
+				t.Errorf("cache refreshed = %v, want %v", refreshed, tt.wantFetch)
+			}
+		})
+	}
+}
+
+func TestMaxAgeValidation(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+	for _, query := range []string{"?max_age=0", "?max_age=-5", "?max_age=soon"} {
+		if w := serve(r, "GET", "/weather/London"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+// serveNotFound answers like Visual Crossing does for an unknown location.
+func serveNotFound(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Bad API Request:Invalid location parameter value.", http.StatusBadRequest)
+}
+
+func withNegativeCacheAfter(t *testing.T, n int) {
+	old := negativeCacheAfter
+	negativeCacheAfter = n
+	t.Cleanup(func() { negativeCacheAfter = old })
+}
+
+func TestNotFoundIsNegativeCached(t *testing.T) {
+	useFakeUpstash(t)
+	withNegativeCacheAfter(t, 1)
+	calls := fakeUpstream(t, serveNotFound)
+	r := testRouter(t)
+
+	for i := 1; i <= 2; i++ {
+		if w := serve(r, "GET", "/weather/Atlantis", nil); w.Code != http.StatusNotFound {
+			t.Fatalf("request %d: status = %d, want 404", i, w.Code)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream calls = %d, want the second request served from the negative cache", n)
+	}
+
+	entry, ok := readCache(context.Background(), cacheKey("Atlantis"))
+	if !ok || !entry.NotFound {
+		t.Fatalf("cached entry = %+v, %v; want a not-found entry", entry, ok)
+	}
+}
+
+func TestSingleNotFoundIsNotCached(t *testing.T) {
+	useFakeUpstash(t)
+	withNegativeCacheAfter(t, 3)
+	calls := fakeUpstream(t, serveNotFound)
+	r := testRouter(t)
+
+	for i := 1; i <= 4; i++ {
+		if w := serve(r, "GET", "/weather/Atlantis", nil); w.Code != http.StatusNotFound {
+			t.Fatalf("request %d: status = %d, want 404", i, w.Code)
+		}
+		if i == 1 {
+			if _, ok := readCache(context.Background(), cacheKey("Atlantis")); ok {
+				t.Fatal("a single not-found was negative-cached")
+			}
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("upstream calls = %d, want 3 before the negative entry is written", n)
+	}
+	if entry, ok := readCache(context.Background(), cacheKey("Atlantis")); !ok || !entry.NotFound {
+		t.Fatalf("cached entry = %+v, %v; want a not-found entry", entry, ok)
+	}
+}
+
+func TestNotFoundCountResetsOnSuccess(t *testing.T) {
+	f := useFakeUpstash(t)
+	withNegativeCacheAfter(t, 3)
+	found := false
+	fakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if found {
+			servePayload(londonPayload)(w, r)
+			return
+		}
+		serveNotFound(w, r)
+	})
+	r := testRouter(t)
+
+	countKey := cacheKey("Atlantis") + ":notfound"
+	serve(r, "GET", "/weather/Atlantis", nil)
+	if n, _ := f.get(countKey); n != "1" {
+		t.Fatalf("not-found count = %q, want 1", n)
+	}
+	found = true
+	if w := serve(r, "GET", "/weather/Atlantis", nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if n, ok := f.get(countKey); ok {
+		t.Errorf("not-found count = %q after a success, want it cleared", n)
+	}
+}
+
+func TestDataAgeHeader(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	w := serve(r, "GET", "/weather/Paris", nil)
+	if got := w.Header().Get("X-Data-Age"); got != "0s" {
+		t.Errorf("fresh fetch X-Data-Age = %q, want 0s", got)
+	}
+
+	cacheAged(t, cacheKey("London"), 3*time.Hour+12*time.Minute)
+	w = serve(r, "GET", "/weather/London", nil)
+	age, err := time.ParseDuration(w.Header().Get("X-Data-Age"))
+	if err != nil {
+		t.Fatalf("X-Data-Age = %q: %v", w.Header().Get("X-Data-Age"), err)
+	}
+	if want := 3*time.Hour + 12*time.Minute; age < want || age > want+5*time.Second {
+		t.Errorf("X-Data-Age = %s, want about %s", age, want)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheProfiles are the Cache-Control max-ages handed to browsers and CDNs,
+// by how quickly the data changes. Override with CACHE_CONTROL_CURRENT,
+// CACHE_CONTROL_FORECAST and CACHE_CONTROL_HISTORY (Go durations).
+var cacheProfiles = map[string]time.Duration{
+	"current":  5 * time.Minute,
+	"forecast": time.Hour,
+	"history":  365 * 24 * time.Hour, // past days never change
+}
+
+// routeCacheProfiles maps routes to a profile. Routes not listed (admin,
+// metrics, streams, POSTs, the CSV download) get no profile.
+var routeCacheProfiles = map[string]string{
+	"/weather/:city":                   "forecast",
+	"/weather/:city/now":               "current",
+	"/weather/:city/card":              "current",
+	"/weather/:city/hourly":            "forecast",
+	"/weather/:city/forecast":          "forecast",
+	"/weather/:city/anomaly":           "forecast",
+	"/weather/:city/accuracy":          "forecast",
+	"/weather/zip/:code":               "forecast",
+	"/weather/:city/history/aggregate": "history",
+	"/validate":                        "forecast",
+}
+
+// addVary adds name to the Vary header unless it is already listed. Any
+// handler that picks its response from a request header must call it,
+// otherwise a shared cache could hand one client's variant to another.
+func addVary(c *gin.Context, name string) {
+	for _, v := range c.Writer.Header().Values("Vary") {
+		for _, listed := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), name) {
+				return
+			}
+		}
+	}
+	c.Writer.Header().Add("Vary", name)
+}
+
+// cacheProfileKey lets a handler override its route's profile for one
+// response.
+const cacheProfileKey = "cacheProfile"
+
+func setupCacheControl() {
+	for name, def := range cacheProfiles {
+		cacheProfiles[name] = envDuration("CACHE_CONTROL_"+strings.ToUpper(name), def)
+	}
+}
+
+// setCacheControl sets Cache-Control for a successful response on the
+// current route. History is marked immutable since the bytes for a past
+// range can never change.
+func setCacheControl(c *gin.Context) {
+	profile := c.GetString(cacheProfileKey)
+	if profile == "" {
+		profile = routeCacheProfiles[c.FullPath()]
+	}
+	maxAge, ok := cacheProfiles[profile]
+	if !ok {
+		return
+	}
+
+	value := "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	if profile == "history" {
+		value += ", immutable"
+	}
+	c.Header("Cache-Control", value)
+}
+
+// historyCacheProfile downgrades a history response to the forecast profile
+// unless its range ended before yesterday (UTC), so a range still being
+// observed somewhere in the world is never cached as immutable.
+func historyCacheProfile(c *gin.Context, end time.Time) {
+	if !end.Before(time.Now().UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)) {
+		c.Set(cacheProfileKey, "forecast")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const fullPayload = `{
+	"resolvedAddress": "London",
+	"timezone": "Europe/London",
+	"currentConditions": {"datetime": "12:00:00", "temp": 18},
+	"days": [{"datetime": "2024-05-01", "tempmax": 20, "hours": [{"datetime": "12:00:00", "datetimeEpoch": 4102444800}]}]
+}`
+
+func varies(h http.Header, name string) bool {
+	for _, v := range h.Values("Vary") {
+		for _, listed := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestCacheableResponsesVaryOnAccept(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(fullPayload))
+	r := testRouter(t)
+
+	tests := []struct {
+		kind, target string
+	}{
+		{"typed", "/weather/London/now"},
+		{"typed", "/weather/London/hourly"},
+		{"typed", "/weather/London/forecast"},
+		{"json only", "/weather/London"},
+		{"json only", "/weather/London/card"},
+		{"json only", "/weather/zip/10115?country=DE"},
+		{"json only", "/validate?city=London"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			for _, accept := range []string{"", "application/json", browserAccept} {
+				w := serve(r, "GET", tt.target, http.Header{"Accept": {accept}})
+				if w.Code != http.StatusOK {
+					t.Fatalf("Accept %q: status = %d: %s", accept, w.Code, w.Body)
+				}
+				if !strings.HasPrefix(w.Header().Get("Cache-Control"), "public") {
+					t.Errorf("%s, Accept %q: Cache-Control = %q", tt.kind, accept, w.Header().Get("Cache-Control"))
+				}
+				if !varies(w.Header(), "Accept") {
+					t.Errorf("%s, Accept %q: public response without Vary: Accept (%v)", tt.kind, accept, w.Header().Values("Vary"))
+				}
+				if n := len(w.Header().Values("Vary")); n != len(uniqueVary(w.Header())) {
+					t.Errorf("%s: duplicate Vary entries %v", tt.kind, w.Header().Values("Vary"))
+				}
+			}
+		})
+	}
+}
+
+func uniqueVary(h http.Header) map[string]bool {
+	seen := map[string]bool{}
+	for _, v := range h.Values("Vary") {
+		seen[strings.ToLower(v)] = true
+	}
+	return seen
+}
+
+func TestAddVary(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	w.Header().Set("Vary", "Accept-Encoding, accept")
+
+	addVary(c, "Accept")
+	addVary(c, "Accept-Language")
+	addVary(c, "Accept-Language")
+
+	if got := w.Header().Values("Vary"); len(got) != 2 || got[1] != "Accept-Language" {
+		t.Errorf("Vary = %q", got)
+	}
+}
+
+func TestCacheControlPerEndpoint(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(fullPayload))
+	r := testRouter(t)
+
+	today := time.Now().UTC().Format("2006-01-02")
+	lastWeek := time.Now().UTC().AddDate(0, 0, -7).Format("2006-01-02")
+	tests := []struct {
+		name, target, want string
+	}{
+		{"current", "/weather/London/now", "public, max-age=300"},
+		{"card", "/weather/London/card", "public, max-age=300"},
+		{"forecast", "/weather/London/forecast", "public, max-age=3600"},
+		{"hourly", "/weather/London/hourly", "public, max-age=3600"},
+		{"raw", "/weather/London", "public, max-age=3600"},
+		{"past history", "/weather/London/history/aggregate?start=2024-01-01&end=2024-01-31", "public, max-age=31536000, immutable"},
+		{"history ending today", "/weather/London/history/aggregate?start=" + lastWeek + "&end=" + today, "public, max-age=3600"},
+		{"download", "/weather/London/history/download?start=2024-01-01&end=2024-01-31", "no-store"},
+	}
+	for _, tt := range tests {
+		w := serve(r, "GET", tt.target, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d: %s", tt.name, w.Code, w.Body)
+			continue
+		}
+		if got := w.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestErrorsAreNotCacheable(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, serveNotFound)
+	r := testRouter(t)
+
+	if w := serve(r, "GET", "/weather/Atlantis/now", nil); w.Header().Get("Cache-Control") != "" {
+		t.Errorf("404 sent Cache-Control %q", w.Header().Get("Cache-Control"))
+	}
+}
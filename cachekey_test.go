@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withKeyPrefix sets CACHE_KEY_PREFIX for one test.
+func withKeyPrefix(t *testing.T, prefix string) {
+	old := cacheKeyPrefix
+	cacheKeyPrefix = prefix
+	t.Cleanup(func() { cacheKeyPrefix = old })
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		prefix string
+		city   string
+		opts   []string
+		want   string
+	}{
+		{"", "London", nil, cacheSchemaVersion + ":London"},
+		{"prod:weather:", "London", nil, "prod:weather:" + cacheSchemaVersion + ":London"},
+		{"prod:", "10115", []string{"zip", "DE"}, "prod:" + cacheSchemaVersion + ":10115:zip:DE"},
+		{"staging:", "Paris", []string{"lang=fr"}, "staging:" + cacheSchemaVersion + ":Paris:lang=fr"},
+	}
+	for _, tt := range tests {
+		withKeyPrefix(t, tt.prefix)
+		if got := cacheKey(tt.city, tt.opts...); got != tt.want {
+			t.Errorf("cacheKey(%q, %q) with prefix %q = %q, want %q", tt.city, tt.opts, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestCacheKeyPrefixesDoNotCollide(t *testing.T) {
+	withKeyPrefix(t, "prod:")
+	prod := cacheKey("London")
+	withKeyPrefix(t, "staging:")
+	if staging := cacheKey("London"); staging == prod {
+		t.Fatalf("prod and staging share key %q", prod)
+	}
+}
+
+func TestCacheKeyEscapesSeparators(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{cacheKey("London:lock"), cacheKey("London") + ":lock"},
+		{cacheKey("10115:zip:DE"), cacheKey("10115", "zip", "DE")},
+		{cacheKey("London", "vc.timezone=a:lock"), cacheKey("London", "vc.timezone=a") + ":lock"},
+		{cacheKey("a%3Ab"), cacheKey("a:b")},
+	}
+	for _, tt := range tests {
+		if tt.a == tt.b {
+			t.Errorf("distinct lookups share key %q", tt.a)
+		}
+	}
+	if got, want := cacheKey("São Paulo"), cacheSchemaVersion+":São Paulo"; got != want {
+		t.Errorf("cacheKey(São Paulo) = %q, want %q", got, want)
+	}
+}
+
+func TestStateKeys(t *testing.T) {
+	withKeyPrefix(t, "prod:")
+	day := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+
+	if got, want := usageKey(day), stateKey("usage", "2024-03-01"); got != want {
+		t.Errorf("usageKey = %q, want %q", got, want)
+	}
+	for name, key := range map[string]string{"usage": usageKey(day), "maintenance": maintenanceKey()} {
+		if !strings.HasPrefix(key, "prod:") {
+			t.Errorf("%s key %q ignores CACHE_KEY_PREFIX", name, key)
+		}
+		for _, city := range []string{metaKeyCity, "_meta", "maintenance"} {
+			if strings.HasPrefix(key, cacheKey(city)+":") || key == cacheKey(city) {
+				t.Errorf("%s key %q is reachable from the city %q", name, key, city)
+			}
+		}
+	}
+}
+
+// TestCityCannotReachServiceKeys replays city names that spell out service
+// keys: enough not-founds to write a negative entry must not land on the
+// usage counter or a fill lock.
+func TestCityCannotReachServiceKeys(t *testing.T) {
+	f := useFakeUpstash(t)
+	calls := fakeUpstream(t, serveNotFound)
+	r := testRouter(t)
+
+	usage := usageKey(time.Now())
+	f.set(usage, "5", time.Hour)
+	lock := cacheKey("London") + ":lock"
+
+	for _, city := range []string{usage, "_meta:usage:" + time.Now().UTC().Format("2006-01-02"), "London:lock"} {
+		for i := 0; i < negativeCacheAfter+1; i++ {
+			if w := serve(r, "GET", "/weather/"+url.PathEscape(city), nil); w.Code != http.StatusNotFound {
+				t.Fatalf("%s: status = %d, want 404", city, w.Code)
+			}
+		}
+	}
+	// Upstream calls still count; the key must hold a counter, not an entry
+	if v, _ := f.get(usage); v != strconv.Itoa(5+int(calls.Load())) {
+		t.Errorf("usage counter = %q, want 5 plus %d upstream calls", v, calls.Load())
+	}
+	if v, ok := f.get(lock); ok {
+		t.Errorf("London's fill lock was written: %q", v)
+	}
+}
+
+func withSchemaVersion(t *testing.T, version string) {
+	old := cacheSchemaVersion
+	cacheSchemaVersion = version
+	t.Cleanup(func() { cacheSchemaVersion = old })
+}
+
+func TestSchemaVersionBumpMissesOldEntries(t *testing.T) {
+	f := useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(londonPayload))
+	withKeyPrefix(t, "prod:")
+	r := testRouter(t)
+
+	withSchemaVersion(t, "v1")
+	v1 := cacheKey("London")
+	serve(r, "GET", "/weather/London", nil)
+	serve(r, "GET", "/weather/London", nil)
+
+	withSchemaVersion(t, "v2")
+	v2 := cacheKey("London")
+	if v1 == v2 || !strings.HasPrefix(v2, "prod:v2:") {
+		t.Fatalf("keys across versions: %q, %q", v1, v2)
+	}
+	if w := serve(r, "GET", "/weather/London", nil); w.Code != 200 {
+		t.Fatalf("status after bump = %d", w.Code)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream calls = %d, want one per version", n)
+	}
+	for _, key := range []string{v1, v2} {
+		if _, ok := f.get(key); !ok {
+			t.Errorf("%q missing; old entries should age out, not be overwritten", key)
+		}
+	}
+}
+
+func TestSchemaVersionBumpKeepsServiceState(t *testing.T) {
+	withKeyPrefix(t, "prod:")
+	withSchemaVersion(t, "v1")
+	usage, maint := usageKey(time.Now()), maintenanceKey()
+
+	withSchemaVersion(t, "v2")
+	if usageKey(time.Now()) != usage || maintenanceKey() != maint {
+		t.Errorf("state keys moved with the schema version: %q, %q", usageKey(time.Now()), maintenanceKey())
+	}
+	if strings.Contains(usage, "v1") {
+		t.Errorf("usage key %q is versioned", usage)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WeatherCard is the body of GET /weather/:city/card, a fixed shape for
+// home-screen widgets. Every field is always present; readings Visual
+// Crossing didn't supply are null and missing text is empty. Don't add
+// fields here: widget code depends on exactly this shape.
+type WeatherCard struct {
+	City      string   `json:"city"`
+	Temp      *float64 `json:"temp"`
+	Icon      string   `json:"icon"`
+	Condition string   `json:"condition"`
+	High      *float64 `json:"high"`
+	Low       *float64 `json:"low"`
+}
+
+func getCard(c *gin.Context) {
+	lang, err := requestLang(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := fetchWeather(c.Request.Context(), weatherQuery{Location: c.Param("city"), Lang: lang, MaxAge: maxAge})
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+
+	var payload struct {
+		weatherPayload
+		Days []struct {
+			TempMax *float64 `json:"tempmax"`
+			TempMin *float64 `json:"tempmin"`
+		} `json:"days"`
+	}
+	if err := json.Unmarshal(entry.Data, &payload); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "unexpected weather data"})
+		return
+	}
+
+	card := WeatherCard{City: payload.ResolvedAddress}
+	if cur := payload.CurrentConditions; cur != nil {
+		card.Temp, card.Icon, card.Condition = cur.Temp, cur.Icon, cur.Conditions
+	}
+	if len(payload.Days) > 0 {
+		card.High, card.Low = payload.Days[0].TempMax, payload.Days[0].TempMin
+	}
+
+	setDataAge(c, entry.FetchedAt)
+	respond(c, http.StatusOK, card)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keyCases are the ?case= values the JSON builder accepts. The default
+// (no ?case=) keeps the struct tags as written.
+var keyCases = map[string]func(string) string{
+	"snake": toSnakeCase,
+	"camel": toCamelCase,
+}
+
+func parseKeyCase(c *gin.Context) (string, error) {
+	name := c.Query("case")
+	if _, ok := keyCases[name]; name != "" && !ok {
+		return "", errors.New("case must be snake or camel")
+	}
+	return name, nil
+}
+
+// toSnakeCase turns "datetimeEpoch" into "datetime_epoch". A run of capitals
+// stays one word, so "maeTempMax" is "mae_temp_max" and "UVIndex" "uv_index".
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := !unicode.IsUpper(runes[i-1]) && runes[i-1] != '_'
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (nextLower && unicode.IsUpper(runes[i-1])) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// toCamelCase turns "datetime_epoch" into "datetimeEpoch"; keys without
+// underscores are returned as they are.
+func toCamelCase(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			r := []rune(parts[i])
+			r[0] = unicode.ToUpper(r[0])
+			parts[i] = string(r)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// transformKeys rewrites every object key in the JSON document data with
+// fn, at any depth. It works on the token stream rather than decoding into
+// maps so field order and number formatting survive unchanged.
+func transformKeys(data []byte, fn func(string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	type frame struct {
+		object bool
+		n      int // keys and values (objects) or elements (arrays) written
+	}
+	var stack []frame
+	var out bytes.Buffer
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			out.WriteRune(rune(d))
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		isKey := false
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			switch {
+			case top.object && top.n%2 == 0:
+				isKey = true
+				if top.n > 0 {
+					out.WriteByte(',')
+				}
+			case top.object:
+				out.WriteByte(':')
+			case top.n > 0:
+				out.WriteByte(',')
+			}
+			top.n++
+		}
+
+		switch v := tok.(type) {
+		case json.Delim:
+			out.WriteRune(rune(v))
+			stack = append(stack, frame{object: v == '{'})
+		case string:
+			if isKey {
+				v = fn(v)
+			}
+			b, _ := json.Marshal(v)
+			out.Write(b)
+		case json.Number:
+			out.WriteString(v.String())
+		case bool:
+			b, _ := json.Marshal(v)
+			out.Write(b)
+		case nil:
+			out.WriteString("null")
+		}
+	}
+}
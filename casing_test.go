@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestKeyCaseConverters(t *testing.T) {
+	snake := map[string]string{
+		"datetimeEpoch": "datetime_epoch",
+		"maeTempMax":    "mae_temp_max",
+		"UVIndex":       "uv_index",
+		"temp":          "temp",
+		"already_snake": "already_snake",
+		"isDaytime":     "is_daytime",
+	}
+	for in, want := range snake {
+		if got := toSnakeCase(in); got != want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	camel := map[string]string{
+		"datetime_epoch":  "datetimeEpoch",
+		"mae_temp_max":    "maeTempMax",
+		"temp":            "temp",
+		"feelslikeSource": "feelslikeSource",
+	}
+	for in, want := range camel {
+		if got := toCamelCase(in); got != want {
+			t.Errorf("toCamelCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTransformKeysKeepsValuesAndOrder(t *testing.T) {
+	in := `{"zKey":1.50,"aKey":{"innerKey":[{"deepKey":"someValue"},2,null,true]},"emptyArr":[],"emptyObj":{}}`
+	want := `{"z_key":1.50,"a_key":{"inner_key":[{"deep_key":"someValue"},2,null,true]},"empty_arr":[],"empty_obj":{}}`
+	got, err := transformKeys([]byte(in), toSnakeCase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("transformKeys =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCaseQueryOnTypedEndpoint(t *testing.T) {
+	r := renderRouter(func() interface{} {
+		return &CurrentResponse{Location: "London", Current: CurrentConditions{DatetimeEpoch: 1, FeelsLikeSource: "reported"}}
+	})
+
+	tests := []struct {
+		query    string
+		want     []string
+		dontWant []string
+	}{
+		{"", []string{`"datetimeEpoch"`, `"feelslikeSource"`, `"isDaytime"`}, []string{`"datetime_epoch"`}},
+		{"?case=snake", []string{`"datetime_epoch"`, `"feelslike_source"`, `"is_daytime"`}, []string{`"datetimeEpoch"`}},
+		{"?case=camel", []string{`"datetimeEpoch"`, `"feelslikeSource"`, `"isDaytime"`}, []string{`"datetime_epoch"`}},
+	}
+	for _, tt := range tests {
+		w := serve(r, "GET", "/v"+tt.query, nil)
+		body := w.Body.String()
+		for _, key := range tt.want {
+			if !strings.Contains(body, key) {
+				t.Errorf("%q: %s lacks %s", tt.query, body, key)
+			}
+		}
+		for _, key := range tt.dontWant {
+			if strings.Contains(body, key) {
+				t.Errorf("%q: %s has %s", tt.query, body, key)
+			}
+		}
+	}
+
+	if w := serve(r, "GET", "/v?case=kebab", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("case=kebab: status = %d, want 400", w.Code)
+	}
+}
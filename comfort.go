@@ -0,0 +1,70 @@
+package main
+
+import "math"
+
+// Where a feelslike value came from.
+const (
+	feelsLikeProvided = "provided" // reported by the provider
+	feelsLikeComputed = "computed" // derived by apparentTemp
+)
+
+// fillFeelsLike keeps the provider's feelslike when there is one and
+// otherwise computes it, returning the source for the response flag. It
+// returns "" when there isn't even a temperature to work from.
+func fillFeelsLike(feelsLike **float64, temp, humidity, windSpeed *float64) string {
+	if *feelsLike != nil {
+		return feelsLikeProvided
+	}
+	if temp == nil {
+		return ""
+	}
+	v := apparentTemp(*temp, humidity, windSpeed)
+	*feelsLike = &v
+	return feelsLikeComputed
+}
+
+// apparentTemp is the NWS apparent temperature in °C for a temperature in
+// °C, relative humidity in percent and wind speed in km/h: wind chill in
+// cold wind, heat index in heat, and the air temperature in between or when
+// the reading needed is missing.
+func apparentTemp(tempC float64, humidity, windKph *float64) float64 {
+	t := celsiusToFahrenheit(tempC)
+	switch {
+	case t <= 50 && windKph != nil && kphToMph(*windKph) >= 3:
+		return fahrenheitToCelsius(windChill(t, kphToMph(*windKph)))
+	case t >= 80 && humidity != nil:
+		return fahrenheitToCelsius(heatIndex(t, *humidity))
+	default:
+		return tempC
+	}
+}
+
+// heatIndex is the NWS heat index in °F: Steadman's simple formula, or the
+// Rothfusz regression with its low- and high-humidity adjustments once the
+// result reaches 80°F.
+// https://www.wpc.ncep.noaa.gov/html/heatindex_equation.shtml
+func heatIndex(t, rh float64) float64 {
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 < 80 {
+		return hi
+	}
+
+	hi = -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+		0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+		0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	switch {
+	case rh < 13 && t >= 80 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t >= 80 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return hi
+}
+
+// windChill is the NWS wind chill in °F for t in °F and wind in mph, valid
+// for t <= 50°F and wind >= 3 mph.
+// https://www.weather.gov/media/epz/wxcalc/windChill.pdf
+func windChill(t, mph float64) float64 {
+	v := math.Pow(mph, 0.16)
+	return 35.74 + 0.6215*t - 35.75*v + 0.4275*t*v
+}
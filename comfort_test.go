@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Reference values are read off the NWS heat index and wind chill charts,
+// which round to whole degrees.
+func TestHeatIndexMatchesNWSChart(t *testing.T) {
+	tests := []struct{ t, rh, want float64 }{
+		{80, 40, 80},
+		{90, 50, 95},
+		{96, 65, 121},
+		{100, 40, 109},
+		{86, 90, 105},
+		{104, 10, 98},
+	}
+	for _, tt := range tests {
+		if got := heatIndex(tt.t, tt.rh); math.Abs(got-tt.want) > 1 {
+			t.Errorf("heatIndex(%v°F, %v%%) = %.1f, want %v", tt.t, tt.rh, got, tt.want)
+		}
+	}
+}
+
+func TestWindChillMatchesNWSChart(t *testing.T) {
+	tests := []struct{ t, mph, want float64 }{
+		{40, 5, 36},
+		{30, 10, 21},
+		{0, 15, -19},
+		{-10, 20, -35},
+		{20, 60, -4},
+	}
+	for _, tt := range tests {
+		if got := windChill(tt.t, tt.mph); math.Abs(got-tt.want) > 1 {
+			t.Errorf("windChill(%v°F, %v mph) = %.1f, want %v", tt.t, tt.mph, got, tt.want)
+		}
+	}
+}
+
+func TestFillFeelsLike(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+
+	reported := f(12)
+	if src := fillFeelsLike(&reported, f(15), nil, nil); src != feelsLikeProvided || *reported != 12 {
+		t.Errorf("provided value: source %q, feelslike %v", src, *reported)
+	}
+
+	var missing *float64
+	if src := fillFeelsLike(&missing, nil, f(50), f(10)); src != "" || missing != nil {
+		t.Errorf("no temperature: source %q, feelslike %v", src, missing)
+	}
+
+	tests := []struct {
+		name           string
+		temp, rh, wind *float64
+		want           float64
+	}{
+		{"mild, air temperature", f(18), f(50), f(10), 18},
+		{"cold and windy, wind chill", f(-1.1), nil, f(16.1), fahrenheitToCelsius(21)},
+		{"cold, calm", f(-5), nil, f(2), -5},
+		{"hot and humid, heat index", f(32.2), f(50), nil, fahrenheitToCelsius(95)},
+		{"hot, no humidity", f(35), nil, nil, 35},
+	}
+	for _, tt := range tests {
+		var fl *float64
+		if src := fillFeelsLike(&fl, tt.temp, tt.rh, tt.wind); src != feelsLikeComputed {
+			t.Errorf("%s: source %q", tt.name, src)
+			continue
+		}
+		if math.Abs(*fl-tt.want) > 0.6 {
+			t.Errorf("%s: feelslike = %.2f°C, want %.2f°C", tt.name, *fl, tt.want)
+		}
+	}
+}
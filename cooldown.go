@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// errRedisThrottled means Upstash answered 429, or we are still inside the
+// cooldown that followed one. Every cache caller already treats Redis errors
+// as a miss and goes to Visual Crossing, so the cache layer simply drops out
+// until the cooldown ends.
+var errRedisThrottled = errors.New("upstash rate limit reached, cache disabled for cooldown")
+
+// redisCooldown is how long Redis is skipped after an Upstash 429 that has
+// no Retry-After (REDIS_COOLDOWN, default 30s).
+var redisCooldown = 30 * time.Second
+
+// redisThrottledUntil holds the cooldown deadline in Unix nanoseconds, zero
+// when Redis is usable.
+var redisThrottledUntil atomic.Int64
+
+// doRedis sends an Upstash REST request with redisClient unless a cooldown
+// is in effect. A 429 starts one, honouring Retry-After when Upstash sends
+// it.
+func doRedis(req *http.Request) (*http.Response, error) {
+	if time.Now().UnixNano() < redisThrottledUntil.Load() {
+		return nil, errRedisThrottled
+	}
+
+	resp, err := redisClient.Do(req)
+	if err != nil {
+		// A canceled or timed-out request says nothing about Redis itself
+		if req.Context().Err() == nil {
+			recordRedisResult(err)
+		}
+		return nil, err
+	}
+	if resp.StatusCode >= 500 {
+		recordRedisResult(fmt.Errorf("upstash returned %s", resp.Status))
+	} else if resp.StatusCode != http.StatusTooManyRequests {
+		recordRedisResult(nil)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	wait := redisCooldown
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		wait = time.Duration(secs) * time.Second
+	}
+	until := time.Now().Add(wait).UnixNano()
+	// Only the request that starts the cooldown logs it.
+	if prev := redisThrottledUntil.Load(); prev < time.Now().UnixNano() && redisThrottledUntil.CompareAndSwap(prev, until) {
+		log.Printf("upstash rate limit reached, skipping cache for %s", wait)
+		alert("redis_throttled", "Upstash rate limit reached, cache disabled for %s", wait)
+	}
+	return nil, errRedisThrottled
+}
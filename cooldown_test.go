@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// throttlingUpstash swaps in an Upstash that answers 429 (with retryAfter,
+// if set) while throttled is true and otherwise defers to f, counting every
+// request that arrives. useFakeUpstash restores the real client afterwards.
+func throttlingUpstash(f *fakeUpstash, retryAfter string) (throttled *atomic.Bool, hits *atomic.Int64) {
+	throttled, hits = &atomic.Bool{}, &atomic.Int64{}
+	redisClient = &http.Client{Transport: handlerTransport{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if throttled.Load() {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		f.ServeHTTP(w, r)
+	})}}
+	return throttled, hits
+}
+
+func TestRedisCooldownAfter429(t *testing.T) {
+	f := useFakeUpstash(t)
+	old := redisCooldown
+	redisCooldown = 200 * time.Millisecond
+	t.Cleanup(func() { redisCooldown = old })
+	throttled, hits := throttlingUpstash(f, "")
+	ctx := context.Background()
+
+	throttled.Store(true)
+	if _, err := redisGet(ctx, "k"); !errors.Is(err, errRedisThrottled) {
+		t.Fatalf("err = %v, want errRedisThrottled", err)
+	}
+	throttled.Store(false)
+
+	// Inside the cooldown nothing is sent
+	for i := 0; i < 5; i++ {
+		if _, err := redisGet(ctx, "k"); !errors.Is(err, errRedisThrottled) {
+			t.Fatalf("during cooldown: err = %v", err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("Upstash saw %d requests, want only the one that was throttled", n)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if err := redisSet(ctx, "k", []byte("v"), time.Minute); err != nil {
+		t.Fatalf("after cooldown: %v", err)
+	}
+	if v, err := redisGet(ctx, "k"); err != nil || v != "v" {
+		t.Errorf("after cooldown: %q, %v", v, err)
+	}
+}
+
+func TestRedisCooldownHonoursRetryAfter(t *testing.T) {
+	f := useFakeUpstash(t)
+	throttled, _ := throttlingUpstash(f, "7")
+	throttled.Store(true)
+
+	start := time.Now()
+	redisGet(context.Background(), "k")
+	until := time.Unix(0, redisThrottledUntil.Load())
+	if wait := until.Sub(start); wait < 6*time.Second || wait > 8*time.Second {
+		t.Errorf("cooldown = %s, want the 7s from Retry-After", wait)
+	}
+}
+
+func TestWeatherServedWithoutCacheWhileThrottled(t *testing.T) {
+	f := useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(londonPayload))
+	throttled, _ := throttlingUpstash(f, "60")
+	throttled.Store(true)
+	r := testRouter(t)
+
+	for i := 0; i < 2; i++ {
+		if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i+1, w.Code)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream calls = %d, want every request to bypass the cache", n)
+	}
+}
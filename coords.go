@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseCoordinateHint reads the optional ?lat=&lon= fallback for a city
+// lookup, returning "" when neither is given and the "lat,lon" location
+// string Visual Crossing accepts otherwise.
+func parseCoordinateHint(c *gin.Context) (string, error) {
+	rawLat, rawLon := c.Query("lat"), c.Query("lon")
+	if rawLat == "" && rawLon == "" {
+		return "", nil
+	}
+	if rawLat == "" || rawLon == "" {
+		return "", errors.New("lat and lon must be given together")
+	}
+
+	lat, err := strconv.ParseFloat(rawLat, 64)
+	if err != nil || math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return "", errors.New("lat must be a number between -90 and 90")
+	}
+	lon, err := strconv.ParseFloat(rawLon, 64)
+	if err != nil || math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return "", errors.New("lon must be a number between -180 and 180")
+	}
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64), nil
+}
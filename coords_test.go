@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"testing"
+)
+
+// upstreamKnowing serves a payload for the given locations and Visual
+// Crossing's not-found answer for anything else.
+func upstreamKnowing(known ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		location := path.Base(r.URL.Path)
+		for _, k := range known {
+			if location == k {
+				servePayload(`{"resolvedAddress":"`+k+`","days":[{}]}`)(w, r)
+				return
+			}
+		}
+		serveNotFound(w, r)
+	}
+}
+
+func TestCoordinateFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		known      []string
+		target     string
+		wantCode   int
+		wantSource string
+		wantCalls  int64
+	}{
+		{"city succeeds", []string{"Springfield", "39.8,-89.65"}, "/weather/Springfield?lat=39.8&lon=-89.65", http.StatusOK, "city", 1},
+		{"coordinates succeed", []string{"39.8,-89.65"}, "/weather/Smallville?lat=39.8&lon=-89.65", http.StatusOK, "coordinates", 2},
+		{"both fail", nil, "/weather/Smallville?lat=39.8&lon=-89.65", http.StatusNotFound, "", 2},
+		{"no hint", nil, "/weather/Smallville", http.StatusNotFound, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeUpstash(t)
+			calls := fakeUpstream(t, upstreamKnowing(tt.known...))
+			r := testRouter(t)
+
+			w := serve(r, "GET", tt.target, nil)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if got := w.Header().Get("X-Location-Source"); got != tt.wantSource {
+				t.Errorf("X-Location-Source = %q, want %q", got, tt.wantSource)
+			}
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCoordinateHintValidation(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, upstreamKnowing("London"))
+	r := testRouter(t)
+	for _, query := range []string{"?lat=51.5", "?lon=-0.1", "?lat=91&lon=0", "?lat=0&lon=181", "?lat=NaN&lon=0", "?lat=north&lon=0"} {
+		if w := serve(r, "GET", "/weather/London"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
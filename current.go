@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CurrentConditions is a compact, typed view of Visual Crossing's
+// currentConditions block. Visual Crossing omits or nulls fields it has no
+// reading for, so every measurement is a pointer and dropped when missing.
+type CurrentConditions struct {
+	Datetime      string   `json:"datetime,omitempty" xml:"datetime,omitempty"`
+	DatetimeEpoch int64    `json:"datetimeEpoch,omitempty" xml:"datetimeEpoch,omitempty"`
+	Temp          *float64 `json:"temp,omitempty" xml:"temp,omitempty"`
+	FeelsLike     *float64 `json:"feelslike,omitempty" xml:"feelslike,omitempty"`
+	Humidity      *float64 `json:"humidity,omitempty" xml:"humidity,omitempty"`
+	WindSpeed     *float64 `json:"windspeed,omitempty" xml:"windspeed,omitempty"`
+	WindDir       *float64 `json:"winddir,omitempty" xml:"winddir,omitempty"`
+	Pressure      *float64 `json:"pressure,omitempty" xml:"pressure,omitempty"`
+	UVIndex       *float64 `json:"uvindex,omitempty" xml:"uvindex,omitempty"`
+	Visibility    *float64 `json:"visibility,omitempty" xml:"visibility,omitempty"`
+	Precip        *float64 `json:"precip,omitempty" xml:"precip,omitempty"`
+	PrecipProb    *float64 `json:"precipprob,omitempty" xml:"precipprob,omitempty"`
+	PrecipType    []string `json:"preciptype,omitempty" xml:"preciptype>type,omitempty"`
+	Conditions    string   `json:"conditions,omitempty" xml:"conditions,omitempty"`
+	Icon          string   `json:"icon,omitempty" xml:"icon,omitempty"`
+	Sunrise       string   `json:"sunrise,omitempty" xml:"sunrise,omitempty"`
+	Sunset        string   `json:"sunset,omitempty" xml:"sunset,omitempty"`
+
+	// Computed rather than read from the payload: the daytime flags by
+	// setDaytime, the summary by precipSummary and the feelslike source by
+	// fillFeelsLike.
+	IsDaytime        bool   `json:"isDaytime" xml:"isDaytime"`
+	DaytimeEstimated bool   `json:"daytimeEstimated,omitempty" xml:"daytimeEstimated,omitempty"`
+	PrecipSummary    string `json:"precipSummary,omitempty" xml:"precipSummary,omitempty"`
+	FeelsLikeSource  string `json:"feelslikeSource,omitempty" xml:"feelslikeSource,omitempty"`
+}
+
+// CurrentResponse is the body returned by GET /weather/:city/now.
+type CurrentResponse struct {
+	XMLName  xml.Name          `json:"-" xml:"weather"`
+	Location string            `json:"location" xml:"location"`
+	Timezone string            `json:"timezone,omitempty" xml:"timezone,omitempty"`
+	Current  CurrentConditions `json:"current" xml:"current"`
+	Source   string            `json:"source" xml:"source"`
+
+	// FetchedAt is when the data left the provider, reported via X-Data-Age.
+	FetchedAt time.Time `json:"-" xml:"-"`
+}
+
+// weatherPayload holds the parts of the Visual Crossing response the typed
+// endpoints care about.
+type weatherPayload struct {
+	ResolvedAddress   string             `json:"resolvedAddress"`
+	Timezone          string             `json:"timezone"`
+	CurrentConditions *CurrentConditions `json:"currentConditions"`
+}
+
+func getCurrent(c *gin.Context) {
+	opts, err := parseResponseOptions(c)
+	if err != nil {
+		badResponseOptions(c, err)
+		return
+	}
+
+	lang, err := requestLang(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := currentConditions(c.Request.Context(), weatherQuery{Location: c.Param("city"), Lang: lang, MaxAge: maxAge})
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+
+	setDataAge(c, resp.FetchedAt)
+	render(c, http.StatusOK, resp, opts)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCurrentOmitsMissingFields(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(`{
+		"resolvedAddress": "London, England, United Kingdom",
+		"timezone": "Europe/London",
+		"days": [{}],
+		"currentConditions": {
+			"datetime": "12:00:00",
+			"temp": 18.5,
+			"humidity": 60,
+			"windspeed": null,
+			"conditions": "Clear"
+		}
+	}`))
+
+	r := gin.New()
+	r.GET("/weather/:city/now", getCurrent)
+	w := serve(r, "GET", "/weather/London/now", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	var body struct {
+		Location string                 `json:"location"`
+		Current  map[string]interface{} `json:"current"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Location != "London, England, United Kingdom" {
+		t.Errorf("location = %q", body.Location)
+	}
+	if body.Current["temp"] != 18.5 || body.Current["humidity"] != 60.0 {
+		t.Errorf("present fields lost: %v", body.Current)
+	}
+	for _, field := range []string{"windspeed", "winddir", "pressure", "uvindex", "visibility"} {
+		if v, ok := body.Current[field]; ok {
+			t.Errorf("%s = %v, want it omitted", field, v)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import "time"
+
+// setDaytime fills IsDaytime by comparing the local observation time with
+// the location's sunrise and sunset. All three are local wall-clock times
+// from the payload, so no timezone conversion is needed.
+//
+// When sun data is missing or unparseable we fall back to a 06:00-18:00 day
+// (or to daytime if even the observation time is unknown) and set
+// DaytimeEstimated so clients can tell.
+func (cc *CurrentConditions) setDaytime() {
+	now, okNow := parseClock(cc.Datetime)
+	sunrise, okRise := parseClock(cc.Sunrise)
+	sunset, okSet := parseClock(cc.Sunset)
+
+	switch {
+	case okNow && okRise && okSet:
+		cc.IsDaytime = isBetweenClock(now, sunrise, sunset)
+		cc.DaytimeEstimated = false
+	case okNow:
+		cc.IsDaytime = isBetweenClock(now, 6*time.Hour, 18*time.Hour)
+		cc.DaytimeEstimated = true
+	default:
+		cc.IsDaytime = true
+		cc.DaytimeEstimated = true
+	}
+}
+
+// isBetweenClock reports whether now lies in [start, end) on a 24h clock,
+// wrapping past midnight when end is earlier than start (e.g. a sunset after
+// midnight at high latitudes).
+func isBetweenClock(now, start, end time.Duration) bool {
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// parseClock returns the time of day of a "15:04:05" clock string or a
+// "2006-01-02T15:04" timestamp as an offset from midnight.
+func parseClock(s string) (time.Duration, bool) {
+	for _, layout := range []string{"15:04:05", "15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Duration(t.Hour())*time.Hour +
+				time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestSetDaytime(t *testing.T) {
+	tests := []struct {
+		name              string
+		now, rise, set    string
+		wantDay, estimate bool
+	}{
+		{"just before sunrise", "06:29:59", "06:30:00", "19:45:00", false, false},
+		{"at sunrise", "06:30:00", "06:30:00", "19:45:00", true, false},
+		{"midday", "12:00:00", "06:30:00", "19:45:00", true, false},
+		{"just before sunset", "19:44:59", "06:30:00", "19:45:00", true, false},
+		{"at sunset", "19:45:00", "06:30:00", "19:45:00", false, false},
+		{"midnight", "00:00:00", "06:30:00", "19:45:00", false, false},
+		{"sunset after midnight, late evening", "23:30:00", "03:10:00", "00:40:00", true, false},
+		{"sunset after midnight, small hours", "00:20:00", "03:10:00", "00:40:00", true, false},
+		{"sunset after midnight, night", "01:00:00", "03:10:00", "00:40:00", false, false},
+		{"timestamp observation", "2024-06-01T13:00:00", "05:00:00", "21:00:00", true, false},
+		{"no sun data, day", "10:00:00", "", "", true, true},
+		{"no sun data, night", "22:00:00", "", "", false, true},
+		{"garbage sunrise", "03:00:00", "dawn", "20:00:00", false, true},
+		{"nothing known", "", "", "", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := CurrentConditions{Datetime: tt.now, Sunrise: tt.rise, Sunset: tt.set}
+			cc.setDaytime()
+			if cc.IsDaytime != tt.wantDay || cc.DaytimeEstimated != tt.estimate {
+				t.Errorf("isDaytime = %v, estimated = %v; want %v, %v",
+					cc.IsDaytime, cc.DaytimeEstimated, tt.wantDay, tt.estimate)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// debugWeather shows how a city lookup would be served without calling
+// Visual Crossing: the upstream URL (key redacted), the cache key, and
+// whether that key is currently cached.
+func debugWeather(c *gin.Context) {
+	lang, err := requestLang(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	extra, err := parseUpstreamParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q := weatherQuery{Location: c.Param("city"), Lang: lang, Extra: extra}
+	key := cacheKey(q.Location, q.keyOptions()...)
+
+	cached, err := redisGet(c.Request.Context(), key)
+	status := gin.H{"cached": err == nil && cached != ""}
+	if err != nil {
+		status["cacheError"] = "cache lookup failed"
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"upstreamUrl": timelineURL(q, "REDACTED"),
+		"cacheKey":    key,
+		"cache":       status,
+	})
+}
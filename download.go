@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// downloadColumns are the CSV columns of a history download, in order. They
+// double as the elements requested from Visual Crossing.
+var downloadColumns = []string{"datetime", "tempmax", "tempmin", "temp", "precip", "humidity", "windspeed", "conditions"}
+
+// downloadFlushEvery is how many rows are written between flushes.
+const downloadFlushEvery = 50
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// downloadDay is one row of a history download.
+type downloadDay struct {
+	Datetime   string   `json:"datetime"`
+	TempMax    *float64 `json:"tempmax"`
+	TempMin    *float64 `json:"tempmin"`
+	Temp       *float64 `json:"temp"`
+	Precip     *float64 `json:"precip"`
+	Humidity   *float64 `json:"humidity"`
+	WindSpeed  *float64 `json:"windspeed"`
+	Conditions string   `json:"conditions"`
+}
+
+func (d downloadDay) record() []string {
+	return []string{d.Datetime, csvFloat(d.TempMax), csvFloat(d.TempMin), csvFloat(d.Temp),
+		csvFloat(d.Precip), csvFloat(d.Humidity), csvFloat(d.WindSpeed), d.Conditions}
+}
+
+func csvFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// downloadHistory streams GET /weather/:city/history/download as CSV. The
+// upstream body is decoded one day at a time and written straight through,
+// so a year-long range never sits in memory; for the same reason it bypasses
+// the cache. Errors before the first row get a normal JSON error; after
+// that the download is simply cut short.
+func downloadHistory(c *gin.Context) {
+	city := c.Param("city")
+	start, end, msg := parseDateRange(c)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	startStr, endStr := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	// Exempt from requestTimeout, so bound the whole transfer here instead.
+	ctx, cancel := context.WithTimeout(c.Request.Context(), envDuration("HISTORY_DOWNLOAD_TIMEOUT", 2*time.Minute))
+	defer cancel()
+
+	upstreamStart := time.Now()
+	resp, err := openUpstream(ctx, weatherQuery{
+		Location: city,
+		Start:    startStr,
+		End:      endStr,
+		Include:  "days",
+		Elements: strings.Join(downloadColumns, ","),
+	})
+	if err != nil {
+		recordUpstreamCall(time.Since(upstreamStart))
+		fetchFailed(c, err)
+		return
+	}
+	defer resp.Body.Close()
+	defer func() { recordUpstreamCall(time.Since(upstreamStart)) }()
+
+	filename := fmt.Sprintf("%s_%s_%s.csv", unsafeFilenameChars.ReplaceAllString(city, "_"), startStr, endStr)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	// The status goes out before the body, so an upstream error mid-stream
+	// leaves a truncated file behind a 200; never let a cache keep it
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	flush := func() {
+		w.Flush()
+		c.Writer.Flush()
+	}
+	defer flush()
+
+	w.Write(downloadColumns)
+	rows := 0
+	err = streamDays(json.NewDecoder(resp.Body), func(d downloadDay) error {
+		if err := w.Write(d.record()); err != nil {
+			return err
+		}
+		if rows++; rows%downloadFlushEvery == 0 {
+			flush()
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		log.Printf("history download for %q aborted after %d rows: %v", city, rows, err)
+	}
+}
+
+// streamDays walks a Visual Crossing timeline object and calls fn for each
+// element of its top-level days array, skipping every other field.
+func streamDays(dec *json.Decoder, fn func(downloadDay) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok != "days" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for dec.More() {
+			var d downloadDay
+			if err := dec.Decode(&d); err != nil {
+				return err
+			}
+			if err := fn(d); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return errors.New("unexpected weather data")
+	}
+	return nil
+}
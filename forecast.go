@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxForecastDays is the length of Visual Crossing's default timeline.
+const maxForecastDays = 15
+
+// ForecastDay is one day of GET /weather/:city/forecast.
+type ForecastDay struct {
+	Datetime   string   `json:"datetime" xml:"datetime"`
+	TempMax    *float64 `json:"tempmax" xml:"tempmax,omitempty"`
+	TempMin    *float64 `json:"tempmin" xml:"tempmin,omitempty"`
+	Temp       *float64 `json:"temp" xml:"temp,omitempty"`
+	FeelsLike  *float64 `json:"feelslike" xml:"feelslike,omitempty"`
+	Humidity   *float64 `json:"humidity" xml:"humidity,omitempty"`
+	WindSpeed  *float64 `json:"windspeed" xml:"windspeed,omitempty"`
+	PrecipProb *float64 `json:"precipprob" xml:"precipprob,omitempty"`
+	PrecipType []string `json:"preciptype" xml:"preciptype>type,omitempty"`
+	Conditions string   `json:"conditions" xml:"conditions"`
+	Icon       string   `json:"icon,omitempty" xml:"icon,omitempty"`
+
+	// Derived rather than read from the payload, by precipSummary and
+	// fillFeelsLike.
+	PrecipSummary   string `json:"precipSummary,omitempty" xml:"precipSummary,omitempty"`
+	FeelsLikeSource string `json:"feelslikeSource,omitempty" xml:"feelslikeSource,omitempty"`
+}
+
+// ForecastResponse is the body of GET /weather/:city/forecast.
+type ForecastResponse struct {
+	XMLName  xml.Name      `json:"-" xml:"forecast"`
+	Location string        `json:"location" xml:"location"`
+	Timezone string        `json:"timezone,omitempty" xml:"timezone,omitempty"`
+	Days     []ForecastDay `json:"days" xml:"day"`
+
+	FetchedAt time.Time `json:"-" xml:"-"`
+}
+
+func getForecast(c *gin.Context) {
+	count, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || count < 1 || count > maxForecastDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxForecastDays)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative number of days"})
+		return
+	}
+	opts, err := parseResponseOptions(c)
+	if err != nil {
+		badResponseOptions(c, err)
+		return
+	}
+	lang, err := requestLang(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := dailyForecast(c.Request.Context(), weatherQuery{Location: c.Param("city"), Lang: lang, MaxAge: maxAge})
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+	// The offset can only be checked once we know how many days came back.
+	if offset >= len(resp.Days) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("offset must be less than %d, the number of forecast days available", len(resp.Days))})
+		return
+	}
+	resp.Days = forecastWindow(resp.Days, offset, count)
+
+	setDataAge(c, resp.FetchedAt)
+	render(c, http.StatusOK, resp, opts)
+}
+
+func (r *ForecastResponse) convertUnits(u unitOptions) {
+	for i := range r.Days {
+		convert(r.Days[i].TempMax, tempUnits[u.Temp])
+		convert(r.Days[i].TempMin, tempUnits[u.Temp])
+		convert(r.Days[i].Temp, tempUnits[u.Temp])
+		convert(r.Days[i].FeelsLike, tempUnits[u.Temp])
+		convert(r.Days[i].WindSpeed, windUnits[u.Wind])
+	}
+}
+
+// dailyForecast reads every day of the default cached timeline, the same
+// payload the other city endpoints use.
+func dailyForecast(ctx context.Context, q weatherQuery) (*ForecastResponse, error) {
+	entry, err := fetchWeather(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		weatherPayload
+		Days []ForecastDay `json:"days"`
+	}
+	if err := json.Unmarshal(entry.Data, &payload); err != nil {
+		return nil, err
+	}
+	for i := range payload.Days {
+		d := &payload.Days[i]
+		d.PrecipSummary = precipSummary(d.PrecipType, d.PrecipProb)
+		d.FeelsLikeSource = fillFeelsLike(&d.FeelsLike, d.Temp, d.Humidity, d.WindSpeed)
+	}
+
+	return &ForecastResponse{
+		Location:  payload.ResolvedAddress,
+		Timezone:  payload.Timezone,
+		Days:      payload.Days,
+		FetchedAt: entry.FetchedAt,
+	}, nil
+}
+
+// forecastWindow skips the first offset days and returns up to count of the
+// rest, so offset=1&days=3 is tomorrow and the two days after it. Callers
+// must check offset < len(days).
+func forecastWindow(days []ForecastDay, offset, count int) []ForecastDay {
+	days = days[offset:]
+	if len(days) > count {
+		days = days[:count]
+	}
+	return days
+}
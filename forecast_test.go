@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// forecastPayload is a timeline with n days dated 2024-05-01 onwards.
+func forecastPayload(n int) string {
+	days := make([]string, n)
+	for i := range days {
+		days[i] = fmt.Sprintf(`{"datetime":"2024-05-%02d","tempmax":20,"conditions":"Clear"}`, i+1)
+	}
+	return `{"resolvedAddress":"London","days":[` + strings.Join(days, ",") + `]}`
+}
+
+func TestForecastOffsetAndLength(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(forecastPayload(maxForecastDays)))
+	r := testRouter(t)
+
+	tests := []struct {
+		query     string
+		wantCode  int
+		wantFirst string
+		wantLen   int
+	}{
+		{"", http.StatusOK, "2024-05-01", 7},
+		{"?offset=1&days=3", http.StatusOK, "2024-05-02", 3},
+		{"?offset=0&days=15", http.StatusOK, "2024-05-01", 15},
+		{"?offset=10&days=15", http.StatusOK, "2024-05-11", 5},
+		{"?offset=14", http.StatusOK, "2024-05-15", 1},
+		{"?offset=15", http.StatusBadRequest, "", 0},
+		{"?offset=-1", http.StatusBadRequest, "", 0},
+		{"?offset=tomorrow", http.StatusBadRequest, "", 0},
+		{"?days=0", http.StatusBadRequest, "", 0},
+		{"?days=16", http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := serve(r, "GET", "/weather/London/forecast"+tt.query, nil)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var resp ForecastResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Days) != tt.wantLen || resp.Days[0].Datetime != tt.wantFirst {
+				t.Errorf("got %d days from %s, want %d from %s", len(resp.Days), resp.Days[0].Datetime, tt.wantLen, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func TestForecastOffsetBeyondShortTimeline(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(forecastPayload(3)))
+	r := testRouter(t)
+
+	if w := serve(r, "GET", "/weather/London/forecast?offset=2", nil); w.Code != http.StatusOK {
+		t.Errorf("offset=2 of 3 days: status = %d", w.Code)
+	}
+	w := serve(r, "GET", "/weather/London/forecast?offset=3", nil)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "less than 3") {
+		t.Errorf("offset=3 of 3 days: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// geoResult is one Open-Meteo geocoding match.
+type geoResult struct {
+	Name       string  `json:"name"`
+	Admin1     string  `json:"admin1"`
+	Country    string  `json:"country"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Population int     `json:"population"`
+}
+
+// label is the "Name, Region, Country" form, which Visual Crossing resolves
+// unambiguously.
+func (g geoResult) label() string {
+	label := g.Name
+	for _, part := range []string{g.Admin1, g.Country} {
+		if part != "" {
+			label += ", " + part
+		}
+	}
+	return label
+}
+
+// geocode asks the Open-Meteo geocoder for up to count matches for name,
+// best first.
+func geocode(ctx context.Context, name string, count int) ([]geoResult, error) {
+	var geo struct {
+		Results []geoResult `json:"results"`
+	}
+	geoURL := "https://geocoding-api.open-meteo.com/v1/search?count=" + strconv.Itoa(count) + "&name=" + url.QueryEscape(name)
+	if err := getJSON(ctx, geoURL, &geo); err != nil {
+		return nil, err
+	}
+	return geo.Results, nil
+}
+
+// geocodeTTL is how long geocoder candidates stay cached; like resolved
+// names, they practically never change.
+const geocodeTTL = 30 * 24 * time.Hour
+
+// ambiguityShare is how populous, relative to the largest same-named place,
+// another place must be to count as a strong candidate. It keeps Paris,
+// Texas from making Paris ambiguous while Springfield still is.
+const ambiguityShare = 0.1
+
+// Candidate is one suggestion in a disambiguation response. Query is what to
+// send as :city to get that place.
+type Candidate struct {
+	Name      string  `json:"name"`
+	Region    string  `json:"region,omitempty"`
+	Country   string  `json:"country"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Query     string  `json:"query"`
+}
+
+// disambiguateCity answers 300 Multiple Choices when a bare city name (no
+// region or country after a comma) matches several comparably sized places,
+// listing them so the client can re-query with the one it meant. It is
+// opt-in via DISAMBIGUATE_CITIES=true since it adds a geocoder lookup, cached
+// per name, ahead of the weather fetch. If the geocoder fails the request
+// proceeds as usual.
+func disambiguateCity(c *gin.Context) {
+	city := strings.TrimSpace(c.Param("city"))
+	if c.Request.Method != http.MethodGet || city == "" || strings.Contains(city, ",") {
+		c.Next()
+		return
+	}
+
+	candidates, err := cityCandidates(c.Request.Context(), city)
+	if err != nil {
+		log.Printf("disambiguation skipped for %q: %v", city, err)
+		c.Next()
+		return
+	}
+	if strong := strongCandidates(city, candidates); len(strong) > 1 {
+		c.AbortWithStatusJSON(http.StatusMultipleChoices, gin.H{
+			"error":      "ambiguous location, re-query with one of the candidates",
+			"candidates": strong,
+		})
+		return
+	}
+	c.Next()
+}
+
+// setupDisambiguation returns disambiguateCity when DISAMBIGUATE_CITIES is
+// enabled, nil otherwise.
+func setupDisambiguation() gin.HandlerFunc {
+	if os.Getenv("DISAMBIGUATE_CITIES") != "true" {
+		return nil
+	}
+	return disambiguateCity
+}
+
+// cityCandidates returns the geocoder's matches for city, cached for
+// geocodeTTL.
+func cityCandidates(ctx context.Context, city string) ([]geoResult, error) {
+	key := cacheKey(normalizeCity(city), "geocode")
+	var results []geoResult
+	if entry, ok := readCache(ctx, key); ok && json.Unmarshal(entry.Data, &results) == nil {
+		return results, nil
+	}
+
+	results, err := geocode(ctx, city, 10)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(results)
+	_ = writeCache(ctx, key, newEntry(body), geocodeTTL)
+	return results, nil
+}
+
+// strongCandidates keeps the results named exactly city (ignoring case)
+// whose population is at least ambiguityShare of the largest of them,
+// collapsing duplicates of the same region and country.
+func strongCandidates(city string, results []geoResult) []Candidate {
+	var named []geoResult
+	largest := 0
+	for _, r := range results {
+		if strings.EqualFold(r.Name, city) {
+			named = append(named, r)
+			if r.Population > largest {
+				largest = r.Population
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	candidates := []Candidate{}
+	for _, r := range named {
+		if float64(r.Population) < ambiguityShare*float64(largest) || seen[r.label()] {
+			continue
+		}
+		seen[r.label()] = true
+		candidates = append(candidates, Candidate{
+			Name:      r.Name,
+			Region:    r.Admin1,
+			Country:   r.Country,
+			Latitude:  r.Latitude,
+			Longitude: r.Longitude,
+			Query:     r.label(),
+		})
+	}
+	return candidates
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+var springfields = []geoResult{
+	{Name: "Springfield", Admin1: "Illinois", Country: "United States", Population: 116250},
+	{Name: "Springfield", Admin1: "Missouri", Country: "United States", Population: 169176},
+	{Name: "Springfield", Admin1: "Massachusetts", Country: "United States", Population: 155929},
+	{Name: "Springfield", Admin1: "Missouri", Country: "United States", Population: 160000},
+	{Name: "Springfield", Admin1: "Tasmania", Country: "Australia", Population: 900},
+	{Name: "Springfield Gardens", Admin1: "New York", Country: "United States", Population: 500000},
+}
+
+var parises = []geoResult{
+	{Name: "Paris", Admin1: "Île-de-France", Country: "France", Population: 2138551},
+	{Name: "Paris", Admin1: "Texas", Country: "United States", Population: 25171},
+}
+
+func TestStrongCandidates(t *testing.T) {
+	got := strongCandidates("springfield", springfields)
+	want := []string{
+		"Springfield, Illinois, United States",
+		"Springfield, Missouri, United States",
+		"Springfield, Massachusetts, United States",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("candidates = %+v, want %v", got, want)
+	}
+	for i, c := range got {
+		if c.Query != want[i] {
+			t.Errorf("candidate %d = %q, want %q", i, c.Query, want[i])
+		}
+	}
+
+	if got := strongCandidates("Paris", parises); len(got) != 1 || got[0].Country != "France" {
+		t.Errorf("Paris candidates = %+v, want only Paris, France", got)
+	}
+	if got := strongCandidates("Atlantis", nil); len(got) != 0 {
+		t.Errorf("no results gave candidates %+v", got)
+	}
+}
+
+// geocoderAnd answers Open-Meteo geocoding requests from places (by name)
+// and passes everything else to weather, counting geocoder calls.
+func geocoderAnd(places map[string][]geoResult, weather http.HandlerFunc) (http.HandlerFunc, *atomic.Int64) {
+	var calls atomic.Int64
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "geocoding-api.open-meteo.com" {
+			weather(w, r)
+			return
+		}
+		calls.Add(1)
+		if places == nil {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": places[r.URL.Query().Get("name")]})
+	}, &calls
+}
+
+func TestDisambiguateCity(t *testing.T) {
+	t.Setenv("DISAMBIGUATE_CITIES", "true")
+	useFakeUpstash(t)
+	handler, geocoderCalls := geocoderAnd(map[string][]geoResult{"Springfield": springfields, "Paris": parises}, servePayload(londonPayload))
+	fakeUpstream(t, handler)
+	r := testRouter(t)
+
+	for i := 1; i <= 2; i++ {
+		w := serve(r, "GET", "/weather/Springfield", nil)
+		if w.Code != http.StatusMultipleChoices {
+			t.Fatalf("request %d: status = %d, want 300", i, w.Code)
+		}
+		var body struct {
+			Candidates []Candidate `json:"candidates"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Candidates) != 3 {
+			t.Fatalf("request %d: body = %s", i, w.Body)
+		}
+	}
+	if n := geocoderCalls.Load(); n != 1 {
+		t.Errorf("geocoder calls = %d, want the candidates served from the cache", n)
+	}
+
+	for _, target := range []string{"/weather/Paris", "/weather/Springfield,%20Illinois"} {
+		if w := serve(r, "GET", target, nil); w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", target, w.Code)
+		}
+	}
+	if n := geocoderCalls.Load(); n != 2 {
+		t.Errorf("geocoder calls = %d, want none for a qualified name", n)
+	}
+}
+
+func TestDisambiguationSkippedWhenGeocoderFails(t *testing.T) {
+	t.Setenv("DISAMBIGUATE_CITIES", "true")
+	useFakeUpstash(t)
+	handler, _ := geocoderAnd(nil, servePayload(londonPayload))
+	fakeUpstream(t, handler)
+	r := testRouter(t)
+
+	if w := serve(r, "GET", "/weather/Springfield", nil); w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 when the geocoder is down", w.Code)
+	}
+}
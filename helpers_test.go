@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard // newRouter's request log
+}
+
+// handlerTransport serves requests with an in-process handler, so tests can
+// stand in for Visual Crossing or Upstash without a network. Like a real
+// transport it gives up once the request context is done.
+type handlerTransport struct{ h http.Handler }
+
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := r.Context().Err(); err != nil {
+		return nil, err
+	}
+	w := httptest.NewRecorder()
+	t.h.ServeHTTP(w, r)
+	if err := r.Context().Err(); err != nil {
+		return nil, err
+	}
+	return w.Result(), nil
+}
+
+// fakeUpstream replaces Visual Crossing with h for one test and reports how
+// many requests reached it.
+func fakeUpstream(t *testing.T, h http.HandlerFunc) *atomic.Int64 {
+	var calls atomic.Int64
+	old := weatherClient
+	weatherClient = &http.Client{Transport: handlerTransport{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		h(w, r)
+	})}}
+	t.Cleanup(func() { weatherClient = old })
+	return &calls
+}
+
+// servePayload answers every upstream request with body.
+func servePayload(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}
+}
+
+// fakeUpstash is an in-memory stand-in for the Upstash REST API covering
+// the commands the service sends.
+type fakeUpstash struct {
+	mu       sync.Mutex
+	data     map[string]string
+	expires  map[string]time.Time
+	commands []string // command names in the order received
+}
+
+// useFakeUpstash points the Redis helpers at a fresh fakeUpstash for one
+// test.
+func useFakeUpstash(t *testing.T) *fakeUpstash {
+	f := &fakeUpstash{data: map[string]string{}, expires: map[string]time.Time{}}
+	oldURL, oldClient := redisURL, redisClient
+	redisURL = "http://upstash.test"
+	redisClient = &http.Client{Transport: handlerTransport{f}}
+	redisThrottledUntil.Store(0)
+	t.Cleanup(func() {
+		redisURL, redisClient = oldURL, oldClient
+		redisThrottledUntil.Store(0)
+	})
+	return f
+}
+
+func (f *fakeUpstash) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.EscapedPath()
+	var reply interface{}
+	switch {
+	case r.Method == "GET" && strings.HasPrefix(path, "/get/"):
+		key, _ := url.PathUnescape(strings.TrimPrefix(path, "/get/"))
+		reply = f.exec([]interface{}{"GET", key})
+	case r.Method == "POST" && strings.HasPrefix(path, "/set/"):
+		key, _ := url.PathUnescape(strings.TrimPrefix(path, "/set/"))
+		value, _ := io.ReadAll(r.Body)
+		cmd := []interface{}{"SET", key, string(value)}
+		if ex := r.URL.Query().Get("EX"); ex != "" {
+			cmd = append(cmd, "EX", ex)
+		}
+		reply = f.exec(cmd)
+	case r.Method == "POST" && path == "/pipeline":
+		var cmds [][]interface{}
+		json.NewDecoder(r.Body).Decode(&cmds)
+		replies := make([]interface{}, len(cmds))
+		for i, cmd := range cmds {
+			replies[i] = f.exec(cmd)
+		}
+		json.NewEncoder(w).Encode(replies)
+		return
+	case r.Method == "POST" && (path == "" || path == "/"):
+		var cmd []interface{}
+		json.NewDecoder(r.Body).Decode(&cmd)
+		reply = f.exec(cmd)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(reply)
+}
+
+func (f *fakeUpstash) result(v interface{}) map[string]interface{} {
+	return map[string]interface{}{"result": v}
+}
+
+// exec runs one command and returns its Upstash reply object.
+func (f *fakeUpstash) exec(cmd []interface{}) map[string]interface{} {
+	args := make([]string, len(cmd))
+	for i, a := range cmd {
+		args[i] = fmt.Sprint(a)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := strings.ToUpper(args[0])
+	f.commands = append(f.commands, name)
+	for key, at := range f.expires {
+		if time.Now().After(at) {
+			delete(f.data, key)
+			delete(f.expires, key)
+		}
+	}
+
+	switch name {
+	case "PING":
+		return f.result("PONG")
+	case "GET":
+		if v, ok := f.data[args[1]]; ok {
+			return f.result(v)
+		}
+		return f.result(nil)
+	case "SET":
+		key, value := args[1], args[2]
+		var ttl time.Duration
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "EX":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Second
+				i++
+			case "PX":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Millisecond
+				i++
+			}
+		}
+		if _, exists := f.data[key]; nx && exists {
+			return f.result(nil)
+		}
+		f.data[key] = value
+		delete(f.expires, key)
+		if ttl > 0 {
+			f.expires[key] = time.Now().Add(ttl)
+		}
+		return f.result("OK")
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := f.data[key]; ok {
+				delete(f.data, key)
+				delete(f.expires, key)
+				n++
+			}
+		}
+		return f.result(n)
+	case "INCR", "INCRBY":
+		n, _ := strconv.Atoi(f.data[args[1]])
+		by := 1
+		if len(args) > 2 {
+			by, _ = strconv.Atoi(args[2])
+		}
+		n += by
+		f.data[args[1]] = strconv.Itoa(n)
+		return f.result(n)
+	case "EXPIRE":
+		if _, ok := f.data[args[1]]; !ok {
+			return f.result(0)
+		}
+		n, _ := strconv.Atoi(args[2])
+		f.expires[args[1]] = time.Now().Add(time.Duration(n) * time.Second)
+		return f.result(1)
+	case "TTL":
+		if _, ok := f.data[args[1]]; !ok {
+			return f.result(-2)
+		}
+		at, ok := f.expires[args[1]]
+		if !ok {
+			return f.result(-1)
+		}
+		return f.result(int(time.Until(at).Seconds()))
+	case "MEMORY":
+		return f.result(len(f.data[args[2]]))
+	case "EVAL":
+		// Only releaseLockScript is ever sent: DEL KEYS[1] if it holds ARGV[1]
+		key, token := args[3], args[4]
+		if f.data[key] == token {
+			delete(f.data, key)
+			delete(f.expires, key)
+			return f.result(1)
+		}
+		return f.result(0)
+	case "SCAN":
+		pattern := globPattern(args[3])
+		keys := []string{}
+		for key := range f.data {
+			if pattern.MatchString(key) {
+				keys = append(keys, key)
+			}
+		}
+		return f.result([]interface{}{"0", keys})
+	}
+	return map[string]interface{}{"error": "ERR unknown command " + name}
+}
+
+// globPattern compiles a Redis MATCH glob (*, ?, [...] and \ escapes).
+func globPattern(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch ch := runes[i]; ch {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				b.WriteString(`\[`)
+				continue
+			}
+			b.WriteString(string(runes[i : end+1]))
+			i = end
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(runes[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// set stores a key directly, without going through the service.
+func (f *fakeUpstash) set(key, value string, ttl time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.data[key] = value
+	if ttl > 0 {
+		f.expires[key] = time.Now().Add(ttl)
+	}
+}
+
+func (f *fakeUpstash) get(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.data[key]
+	return v, ok
+}
+
+func (f *fakeUpstash) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.data))
+	for key := range f.data {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// serve runs one request against r and returns the recorded response.
+func serve(r http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// testRouter builds the real router with a rate limit high enough that
+// tests never trip it.
+func testRouter(t *testing.T) *gin.Engine {
+	if _, ok := os.LookupEnv("RATE_LIMIT"); !ok {
+		t.Setenv("RATE_LIMIT", "1000-S")
+	}
+	return newRouter()
+}
+
+// serveJSON runs one request with a JSON body against r.
+func serveJSON(r http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
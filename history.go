@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxHistoryDays caps how many days one history request may span. Visual
+// Crossing bills historical queries per day, so this bounds the cost.
+const maxHistoryDays = 366
+
+// historyDay is the subset of a Visual Crossing day used for aggregation.
+type historyDay struct {
+	Datetime string   `json:"datetime"`
+	Temp     *float64 `json:"temp"`
+	Precip   *float64 `json:"precip"`
+}
+
+// HistoryBucket is one aggregated period. Start and End are the nominal
+// period bounds; Days is how many days of data fell inside it, so a trailing
+// week or month cut short by the requested range shows Complete=false.
+type HistoryBucket struct {
+	Start       string   `json:"start"`
+	End         string   `json:"end"`
+	Days        int      `json:"days"`
+	Complete    bool     `json:"complete"`
+	AvgTemp     *float64 `json:"avgTemp"`
+	TotalPrecip float64  `json:"totalPrecip"`
+}
+
+// parseDateRange validates the start/end query params, returning a
+// user-facing error message when they are unusable.
+func parseDateRange(c *gin.Context) (start, end time.Time, msg string) {
+	start, err := time.Parse("2006-01-02", c.Query("start"))
+	if err != nil {
+		return start, end, "start must be a date in YYYY-MM-DD format"
+	}
+	end, err = time.Parse("2006-01-02", c.Query("end"))
+	if err != nil {
+		return start, end, "end must be a date in YYYY-MM-DD format"
+	}
+	if end.Before(start) {
+		return start, end, "end must not be before start"
+	}
+	if end.Sub(start) >= maxHistoryDays*24*time.Hour {
+		return start, end, "date range is limited to 366 days"
+	}
+	return start, end, ""
+}
+
+func getHistoryAggregate(c *gin.Context) {
+	city := c.Param("city")
+	granularity := c.DefaultQuery("granularity", "day")
+	if granularity != "day" && granularity != "week" && granularity != "month" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be day, week or month"})
+		return
+	}
+
+	start, end, msg := parseDateRange(c)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	startStr, endStr := start.Format("2006-01-02"), end.Format("2006-01-02")
+	entry, err := fetchLocation(c.Request.Context(), weatherQuery{
+		Location: city,
+		Start:    startStr,
+		End:      endStr,
+		Include:  "days",
+		Elements: "datetime,temp,precip",
+	}, cacheKey(city, "history", startStr, endStr))
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+
+	var payload struct {
+		ResolvedAddress string       `json:"resolvedAddress"`
+		Days            []historyDay `json:"days"`
+	}
+	if err := json.Unmarshal(entry.Data, &payload); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "unexpected weather data"})
+		return
+	}
+
+	setDataAge(c, entry.FetchedAt)
+	historyCacheProfile(c, end)
+	respond(c, http.StatusOK, gin.H{
+		"location":    payload.ResolvedAddress,
+		"granularity": granularity,
+		"start":       startStr,
+		"end":         endStr,
+		"periods":     aggregateHistory(payload.Days, granularity),
+	})
+}
+
+// aggregateHistory groups chronologically ordered days into buckets. Weeks
+// start on Monday (ISO 8601). Days with a missing temperature still count
+// toward the bucket but are left out of the average.
+func aggregateHistory(days []historyDay, granularity string) []HistoryBucket {
+	buckets := []HistoryBucket{}
+	var tempSum float64
+	var tempCount int
+
+	flush := func() {
+		b := &buckets[len(buckets)-1]
+		if tempCount > 0 {
+			avg := tempSum / float64(tempCount)
+			b.AvgTemp = &avg
+		}
+		tempSum, tempCount = 0, 0
+	}
+
+	for _, d := range days {
+		day, err := time.Parse("2006-01-02", d.Datetime)
+		if err != nil {
+			continue
+		}
+
+		periodStart, periodEnd := periodBounds(day, granularity)
+		if len(buckets) == 0 || buckets[len(buckets)-1].Start != periodStart.Format("2006-01-02") {
+			if len(buckets) > 0 {
+				flush()
+			}
+			buckets = append(buckets, HistoryBucket{
+				Start: periodStart.Format("2006-01-02"),
+				End:   periodEnd.Format("2006-01-02"),
+			})
+		}
+
+		b := &buckets[len(buckets)-1]
+		b.Days++
+		b.Complete = b.Days == int(periodEnd.Sub(periodStart).Hours()/24)+1
+		if d.Temp != nil {
+			tempSum += *d.Temp
+			tempCount++
+		}
+		if d.Precip != nil {
+			b.TotalPrecip += *d.Precip
+		}
+	}
+	if len(buckets) > 0 {
+		flush()
+	}
+	return buckets
+}
+
+// periodBounds returns the first and last day of the period containing day.
+func periodBounds(day time.Time, granularity string) (time.Time, time.Time) {
+	switch granularity {
+	case "week":
+		offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+		start := day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 6)
+	case "month":
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, -1)
+	default:
+		return day, day
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hotCities tracks the most requested cities using the Space-Saving
+// algorithm: at most hotCitiesCapacity counters are kept, and a new city
+// evicts the least counted one, inheriting its count. Memory stays bounded no
+// matter how many distinct cities are requested, and the heavy hitters are
+// reported accurately.
+const hotCitiesCapacity = 200
+
+type hotCounter struct {
+	count int64
+	// overcount is the count inherited on eviction, i.e. the maximum by which
+	// count may exceed the true number of requests.
+	overcount int64
+}
+
+var hotCities = struct {
+	sync.Mutex
+	counters    map[string]*hotCounter
+	windowStart time.Time
+}{counters: map[string]*hotCounter{}, windowStart: time.Now()}
+
+// startHotCitiesWindow resets the counters every HOT_CITIES_WINDOW (default
+// 1h) so the ranking reflects recent traffic.
+func startHotCitiesWindow() {
+	window := envDuration("HOT_CITIES_WINDOW", time.Hour)
+	if window <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(window) {
+			hotCities.Lock()
+			hotCities.counters = map[string]*hotCounter{}
+			hotCities.windowStart = time.Now()
+			hotCities.Unlock()
+		}
+	}()
+}
+
+func recordCityRequest(city string) {
+	city = strings.ToLower(strings.TrimSpace(city))
+
+	hotCities.Lock()
+	defer hotCities.Unlock()
+
+	if ctr, ok := hotCities.counters[city]; ok {
+		ctr.count++
+		return
+	}
+	if len(hotCities.counters) < hotCitiesCapacity {
+		hotCities.counters[city] = &hotCounter{count: 1}
+		return
+	}
+
+	var minCity string
+	var min *hotCounter
+	for name, ctr := range hotCities.counters {
+		if min == nil || ctr.count < min.count {
+			minCity, min = name, ctr
+		}
+	}
+	delete(hotCities.counters, minCity)
+	hotCities.counters[city] = &hotCounter{count: min.count + 1, overcount: min.count}
+}
+
+// HotCity is one entry of GET /admin/cache/top.
+type HotCity struct {
+	City     string `json:"city"`
+	Requests int64  `json:"requests"`
+	// MaxError bounds how much Requests may be overstated.
+	MaxError int64 `json:"maxError"`
+}
+
+func topCities(n int) []HotCity {
+	hotCities.Lock()
+	top := make([]HotCity, 0, len(hotCities.counters))
+	for city, ctr := range hotCities.counters {
+		top = append(top, HotCity{City: city, Requests: ctr.count, MaxError: ctr.overcount})
+	}
+	hotCities.Unlock()
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Requests != top[j].Requests {
+			return top[i].Requests > top[j].Requests
+		}
+		return top[i].City < top[j].City
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+func getTopCities(c *gin.Context) {
+	n, err := strconv.Atoi(c.DefaultQuery("n", "10"))
+	if err != nil || n < 1 || n > hotCitiesCapacity {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "n must be between 1 and 200"})
+		return
+	}
+
+	hotCities.Lock()
+	since := hotCities.windowStart
+	hotCities.Unlock()
+
+	respond(c, http.StatusOK, gin.H{"since": since, "cities": topCities(n)})
+}
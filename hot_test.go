@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// resetHotCities clears the counters for one test.
+func resetHotCities(t *testing.T) {
+	reset := func() {
+		hotCities.Lock()
+		hotCities.counters = map[string]*hotCounter{}
+		hotCities.windowStart = time.Now()
+		hotCities.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestTopCitiesReflectsSkewedTraffic(t *testing.T) {
+	resetHotCities(t)
+
+	// A few heavy hitters buried in far more distinct cities than we keep
+	// counters for
+	for i := 0; i < 1000; i++ {
+		recordCityRequest("London")
+		if i%2 == 0 {
+			recordCityRequest(" paris ")
+		}
+		if i%4 == 0 {
+			recordCityRequest("Tokyo")
+		}
+		recordCityRequest(fmt.Sprintf("village-%d", i))
+	}
+
+	hotCities.Lock()
+	size := len(hotCities.counters)
+	hotCities.Unlock()
+	if size > hotCitiesCapacity {
+		t.Errorf("%d counters kept, want at most %d", size, hotCitiesCapacity)
+	}
+
+	top := topCities(3)
+	want := []string{"london", "paris", "tokyo"}
+	if len(top) != len(want) {
+		t.Fatalf("top = %+v", top)
+	}
+	for i, city := range want {
+		if top[i].City != city {
+			t.Errorf("top[%d] = %q, want %q (%+v)", i, top[i].City, city, top)
+		}
+	}
+	if top[0].Requests != 1000 || top[0].MaxError != 0 {
+		t.Errorf("london = %+v, want exactly 1000 requests", top[0])
+	}
+}
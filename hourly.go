@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxHourlyHours = 48
+
+// HourlyEntry is one hour of GET /weather/:city/hourly.
+type HourlyEntry struct {
+	Datetime   string   `json:"datetime" xml:"datetime"`
+	Temp       *float64 `json:"temp" xml:"temp,omitempty"`
+	PrecipProb *float64 `json:"precipprob" xml:"precipprob,omitempty"`
+	Conditions string   `json:"conditions" xml:"conditions"`
+}
+
+// HourlyResponse is the body of GET /weather/:city/hourly.
+type HourlyResponse struct {
+	XMLName  xml.Name      `json:"-" xml:"hourly"`
+	Location string        `json:"location" xml:"location"`
+	Timezone string        `json:"timezone,omitempty" xml:"timezone,omitempty"`
+	Hours    []HourlyEntry `json:"hours" xml:"hour"`
+
+	FetchedAt time.Time `json:"-" xml:"-"`
+}
+
+type payloadHour struct {
+	Datetime      string   `json:"datetime"`
+	DatetimeEpoch int64    `json:"datetimeEpoch"`
+	Temp          *float64 `json:"temp"`
+	PrecipProb    *float64 `json:"precipprob"`
+	Conditions    string   `json:"conditions"`
+}
+
+type payloadDay struct {
+	Datetime string        `json:"datetime"`
+	Hours    []payloadHour `json:"hours"`
+}
+
+func getHourly(c *gin.Context) {
+	count, err := strconv.Atoi(c.DefaultQuery("hours", "12"))
+	if err != nil || count < 1 || count > maxHourlyHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be between 1 and 48"})
+		return
+	}
+	opts, err := parseResponseOptions(c)
+	if err != nil {
+		badResponseOptions(c, err)
+		return
+	}
+	lang, err := requestLang(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := hourlyForecast(c.Request.Context(), weatherQuery{Location: c.Param("city"), Lang: lang, MaxAge: maxAge}, count)
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+
+	setDataAge(c, resp.FetchedAt)
+	render(c, http.StatusOK, resp, opts)
+}
+
+func (r *HourlyResponse) convertUnits(u unitOptions) {
+	for i := range r.Hours {
+		convert(r.Hours[i].Temp, tempUnits[u.Temp])
+	}
+}
+
+// hourlyForecast returns the next count hours starting with the current
+// one. Visual Crossing's default timeline already includes include=hours
+// data, so this reads the same cached payload as the other city endpoints
+// instead of spending quota on a separate request.
+func hourlyForecast(ctx context.Context, q weatherQuery, count int) (*HourlyResponse, error) {
+	entry, err := fetchWeather(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		weatherPayload
+		Days []payloadDay `json:"days"`
+	}
+	if err := json.Unmarshal(entry.Data, &payload); err != nil {
+		return nil, err
+	}
+
+	// Start from the wall clock, not the payload's currentConditions: a
+	// cached payload can be hours old, and those hours have passed
+	return &HourlyResponse{
+		Location:  payload.ResolvedAddress,
+		Timezone:  payload.Timezone,
+		Hours:     nextHours(payload.Days, time.Now().Unix(), count),
+		FetchedAt: entry.FetchedAt,
+	}, nil
+}
+
+// nextHours flattens days→hours in order and returns up to count hours,
+// starting from the hour that contains now (epoch seconds). Comparing epochs
+// rather than clock strings keeps tomorrow's 00:00 after today's 23:00 and
+// works for locations with half-hour UTC offsets.
+func nextHours(days []payloadDay, now int64, count int) []HourlyEntry {
+	hours := []HourlyEntry{}
+	for _, day := range days {
+		for _, h := range day.Hours {
+			if h.DatetimeEpoch+3600 <= now {
+				continue
+			}
+			hours = append(hours, HourlyEntry{
+				Datetime:   day.Datetime + "T" + h.Datetime,
+				Temp:       h.Temp,
+				PrecipProb: h.PrecipProb,
+				Conditions: h.Conditions,
+			})
+			if len(hours) == count {
+				return hours
+			}
+		}
+	}
+	return hours
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// hourlyDays builds n days of 24 hours each, starting at midnight UTC on
+// start.
+func hourlyDays(start time.Time, n int) []payloadDay {
+	days := make([]payloadDay, n)
+	for d := range days {
+		day := start.AddDate(0, 0, d)
+		days[d].Datetime = day.Format("2006-01-02")
+		for h := 0; h < 24; h++ {
+			at := day.Add(time.Duration(h) * time.Hour)
+			days[d].Hours = append(days[d].Hours, payloadHour{
+				Datetime:      at.Format("15:04:05"),
+				DatetimeEpoch: at.Unix(),
+			})
+		}
+	}
+	return days
+}
+
+func TestNextHours(t *testing.T) {
+	start := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	days := hourlyDays(start, 2)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		count     int
+		wantFirst string
+		wantLen   int
+	}{
+		{"on the hour", start.Add(9 * time.Hour), 3, "2024-03-10T09:00:00", 3},
+		{"mid-hour keeps the current hour", start.Add(9*time.Hour + 30*time.Minute), 3, "2024-03-10T09:00:00", 3},
+		{"crosses midnight", start.Add(23 * time.Hour), 2, "2024-03-10T23:00:00", 2},
+		{"runs out of data", start.Add(46 * time.Hour), 12, "2024-03-11T22:00:00", 2},
+		{"past the end", start.Add(72 * time.Hour), 12, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hours := nextHours(days, tt.now.Unix(), tt.count)
+			if len(hours) != tt.wantLen {
+				t.Fatalf("got %d hours, want %d", len(hours), tt.wantLen)
+			}
+			if tt.wantLen > 0 && hours[0].Datetime != tt.wantFirst {
+				t.Errorf("first hour = %s, want %s", hours[0].Datetime, tt.wantFirst)
+			}
+			for i := 1; i < len(hours); i++ {
+				if hours[i].Datetime <= hours[i-1].Datetime {
+					t.Errorf("hours out of order: %s after %s", hours[i].Datetime, hours[i-1].Datetime)
+				}
+			}
+		})
+	}
+
+	if got := nextHours(days, start.Add(23*time.Hour).Unix(), 2); got[1].Datetime != "2024-03-11T00:00:00" {
+		t.Errorf("hour after 23:00 = %s, want next day's midnight", got[1].Datetime)
+	}
+}
+
+func TestNextHoursHalfHourOffset(t *testing.T) {
+	// Local hours in India start at :30 past the UTC hour
+	ist := time.FixedZone("IST", 5*3600+1800)
+	start := time.Date(2024, 3, 10, 0, 0, 0, 0, ist)
+	days := hourlyDays(start, 1)
+
+	now := start.Add(10*time.Hour + 45*time.Minute)
+	hours := nextHours(days, now.Unix(), 1)
+	if want := fmt.Sprintf("%sT10:00:00", start.Format("2006-01-02")); len(hours) != 1 || hours[0].Datetime != want {
+		t.Errorf("hours = %+v, want %s", hours, want)
+	}
+}
+
+// hourlyPayload is a timeline covering yesterday to tomorrow (UTC) whose
+// currentConditions were observed six hours ago, like an aged cache entry.
+func hourlyPayload() string {
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+	body, _ := json.Marshal(map[string]interface{}{
+		"resolvedAddress":   "London",
+		"days":              hourlyDays(start, 3),
+		"currentConditions": map[string]interface{}{"datetimeEpoch": now.Add(-6 * time.Hour).Unix()},
+	})
+	return string(body)
+}
+
+func TestHourlyStartsFromTheCurrentHour(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(hourlyPayload()))
+	r := testRouter(t)
+
+	w := serve(r, "GET", "/weather/London/hourly?hours=3", nil)
+	var resp HourlyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Hours) != 3 {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	if want := time.Now().UTC().Format("2006-01-02T15:00:00"); resp.Hours[0].Datetime != want {
+		t.Errorf("first hour = %s, want the current hour %s", resp.Hours[0].Datetime, want)
+	}
+}
+
+func TestHourlyHonoursMaxAge(t *testing.T) {
+	useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(hourlyPayload()))
+	r := testRouter(t)
+
+	cacheAged(t, cacheKey("London"), 2*time.Hour)
+	if w := serve(r, "GET", "/weather/London/hourly?max_age=60", nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream calls = %d, want an entry older than max_age refetched", n)
+	}
+	if w := serve(r, "GET", "/weather/London/hourly?max_age=soon", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("max_age=soon: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stages reported by GET /admin/inflight.
+const (
+	stageHandler  = "handler"
+	stageCache    = "cache"
+	stageUpstream = "upstream"
+)
+
+// inflightRequest is one request in the registry. Only stage changes after
+// registration, so it is the only field needing synchronisation.
+type inflightRequest struct {
+	method  string
+	path    string
+	city    string
+	started time.Time
+	stage   atomic.Value // string
+}
+
+// inflight tracks requests between trackInflight's start and end. It holds
+// at most inflightLimit entries (INFLIGHT_MAX, default 1000); requests
+// beyond that are served but not tracked, so a flood can't grow it without
+// bound.
+var inflight = struct {
+	sync.Mutex
+	nextID   uint64
+	requests map[uint64]*inflightRequest
+}{requests: map[uint64]*inflightRequest{}}
+
+var inflightLimit = 1000
+
+type inflightKey struct{}
+
+// trackInflight registers the request for its lifetime and puts the entry
+// in the request context so setStage can find it.
+func trackInflight(c *gin.Context) {
+	req := &inflightRequest{
+		method:  c.Request.Method,
+		path:    c.FullPath(),
+		city:    c.Param("city"),
+		started: time.Now(),
+	}
+	req.stage.Store(stageHandler)
+
+	inflight.Lock()
+	if len(inflight.requests) >= inflightLimit {
+		inflight.Unlock()
+		c.Next()
+		return
+	}
+	inflight.nextID++
+	id := inflight.nextID
+	inflight.requests[id] = req
+	inflight.Unlock()
+
+	defer func() {
+		inflight.Lock()
+		delete(inflight.requests, id)
+		inflight.Unlock()
+	}()
+
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), inflightKey{}, req))
+	c.Next()
+}
+
+// setStage records what the request owning ctx is waiting on. It is a no-op
+// for untracked requests and background work.
+func setStage(ctx context.Context, stage string) {
+	if req, ok := ctx.Value(inflightKey{}).(*inflightRequest); ok {
+		req.stage.Store(stage)
+	}
+}
+
+// InflightRequest is one entry of GET /admin/inflight.
+type InflightRequest struct {
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	City      string  `json:"city,omitempty"`
+	Stage     string  `json:"stage"`
+	ElapsedMs float64 `json:"elapsedMs"`
+}
+
+// getInflight lists in-progress requests, longest running first.
+func getInflight(c *gin.Context) {
+	now := time.Now()
+	inflight.Lock()
+	list := make([]InflightRequest, 0, len(inflight.requests))
+	for _, req := range inflight.requests {
+		list = append(list, InflightRequest{
+			Method:    req.method,
+			Path:      req.path,
+			City:      req.city,
+			Stage:     req.stage.Load().(string),
+			ElapsedMs: float64(now.Sub(req.started)) / float64(time.Millisecond),
+		})
+	}
+	inflight.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ElapsedMs > list[j].ElapsedMs })
+	respond(c, http.StatusOK, gin.H{"count": len(list), "requests": list})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// supportedLangs are the lang= values Visual Crossing accepts for
+// translated condition descriptions.
+var supportedLangs = map[string]bool{
+	"ar": true, "bg": true, "cs": true, "da": true, "de": true, "el": true,
+	"en": true, "es": true, "fa": true, "fi": true, "fr": true, "he": true,
+	"hu": true, "it": true, "ja": true, "ko": true, "nl": true, "pl": true,
+	"pt": true, "ru": true, "sk": true, "sr": true, "sv": true, "tr": true,
+	"uk": true, "vi": true, "zh": true,
+}
+
+// requestLang picks the response language: an explicit ?lang= wins (and must
+// be supported), otherwise the best supported match from Accept-Language,
+// otherwise English.
+func requestLang(c *gin.Context) (string, error) {
+	addVary(c, "Accept-Language")
+
+	if lang := strings.ToLower(c.Query("lang")); lang != "" {
+		if !supportedLangs[lang] {
+			return "", errors.New("unsupported lang " + lang)
+		}
+		return lang, nil
+	}
+	return negotiateLang(c.GetHeader("Accept-Language")), nil
+}
+
+// negotiateLang parses an Accept-Language header such as
+// "de-CH;q=0.9,en;q=0.8" and returns the highest-quality supported primary
+// language tag, or "en" when nothing matches.
+func negotiateLang(header string) string {
+	type candidate struct {
+		lang    string
+		quality float64
+	}
+	var candidates []candidate
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				quality = q
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		primary, _, _ := strings.Cut(tag, "-")
+		if supportedLangs[primary] {
+			candidates = append(candidates, candidate{primary, quality})
+		}
+	}
+
+	// Stable so equal qualities keep the client's order.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	if len(candidates) == 0 {
+		return "en"
+	}
+	return candidates[0].lang
+}
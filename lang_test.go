@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateLang(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"de", "de"},
+		{"de-CH;q=0.9,en;q=0.8", "de"},
+		{"en;q=0.5, fr;q=0.9", "fr"},
+		{"xx, es", "es"},
+		{"FR-ca", "fr"},
+		{"pt-BR, pt;q=0.9", "pt"},
+		{"ja;q=0, ko;q=0.1", "ko"},
+		{"*, nl", "nl"},
+		{"xx, yy", "en"},
+		{"it;q=garbage, de;q=0.2", "de"},
+		{"sv, da", "sv"},
+	}
+	for _, tt := range tests {
+		if got := negotiateLang(tt.header); got != tt.want {
+			t.Errorf("negotiateLang(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRequestLangQueryWins(t *testing.T) {
+	tests := []struct {
+		target, header string
+		want           string
+		wantErr        bool
+	}{
+		{"/?lang=ES", "de", "es", false},
+		{"/?lang=klingon", "de", "", true},
+		{"/", "de", "de", false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", tt.target, nil)
+		c.Request.Header.Set("Accept-Language", tt.header)
+
+		got, err := requestLang(c)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%s: requestLang = %q, %v", tt.target, got, err)
+		}
+		if vary := w.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Accept-Language" {
+			t.Errorf("%s: Vary = %v", tt.target, vary)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Cache-aside locking: when several instances miss the same key at once,
+// only the one holding the lock calls Visual Crossing and the rest poll the
+// cache for its result. It is the cross-instance counterpart of singleflight.
+const (
+	fillLockTTL  = 10 * time.Second       // auto-expiry in case the holder dies
+	fillLockWait = 3 * time.Second        // how long waiters poll before giving up
+	fillLockPoll = 100 * time.Millisecond // delay between cache polls
+)
+
+// releaseLockScript deletes the lock only if we still own it, so a holder
+// whose lock already expired can't release someone else's.
+const releaseLockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// fetchAndStore fetches q from upstream and caches it under key, guarded by
+// a Redis lock (SET NX PX). If Redis is unreachable, or the lock holder
+// doesn't produce a result within fillLockWait, we fetch directly.
+func fetchAndStore(ctx context.Context, q weatherQuery, key string) (*cacheEntry, error) {
+	lockKey := key + ":lock"
+	token := lockToken()
+
+	raw, err := redisCommand(ctx, "SET", lockKey, token, "NX", "PX", fillLockTTL.Milliseconds())
+	if err == nil && !acquired(raw) {
+		if entry, ok := waitForFill(ctx, q, key); ok {
+			recordFill(true)
+			return entry.orNotFound()
+		}
+		recordFill(false)
+		body, err := fetchUpstream(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		return newEntry(body), nil
+	}
+	recordFill(false)
+	if err == nil {
+		defer redisCommand(context.Background(), "EVAL", releaseLockScript, 1, lockKey, token)
+	}
+
+	body, err := fetchUpstream(ctx, q)
+	if errors.Is(err, errLocationNotFound) {
+		_ = recordNotFound(ctx, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := newEntry(body)
+	_ = writeCache(ctx, key, entry, q.cacheTTL())
+	clearNotFound(ctx, key)
+	return entry, nil
+}
+
+// waitForFill polls the cache until another instance stores an entry under
+// key that is fresh enough for q.
+func waitForFill(ctx context.Context, q weatherQuery, key string) (*cacheEntry, bool) {
+	setStage(ctx, stageCache)
+	defer setStage(ctx, stageHandler)
+	deadline := time.Now().Add(fillLockWait)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(fillLockPoll):
+		}
+		if entry, ok := readCache(ctx, key); ok && q.freshEnough(entry) {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// acquired reports whether a SET NX reply means we got the lock ("OK"
+// rather than null).
+func acquired(raw json.RawMessage) bool {
+	var reply string
+	return json.Unmarshal(raw, &reply) == nil && reply == "OK"
+}
+
+func lockToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+const lockTestPayload = `{"resolvedAddress":"London","days":[{}]}`
+
+func TestFillLockHolderFetchesOnceForConcurrentMisses(t *testing.T) {
+	f := useFakeUpstash(t)
+	calls := fakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		servePayload(lockTestPayload)(w, r)
+	})
+	q := weatherQuery{Location: "London"}
+	key := cacheKey("london")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry, err := fetchAndStore(context.Background(), q, key)
+			if err == nil && string(entry.Data) != lockTestPayload {
+				t.Errorf("goroutine %d got %s", i, entry.Data)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("goroutine %d: %v", i, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream calls = %d, want 1", n)
+	}
+	if _, ok := f.get(key + ":lock"); ok {
+		t.Error("holder did not release the lock")
+	}
+	if _, ok := f.get(key); !ok {
+		t.Error("holder did not cache the result")
+	}
+}
+
+func TestFillLockWaiterReadsHolderResult(t *testing.T) {
+	f := useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(lockTestPayload))
+	q := weatherQuery{Location: "London"}
+	key := cacheKey("london")
+
+	// Another instance holds the lock and stores its result shortly
+	f.set(key+":lock", "someone-else", fillLockTTL)
+	go func() {
+		time.Sleep(250 * time.Millisecond)
+		_ = writeCache(context.Background(), key, newEntry([]byte(lockTestPayload)), time.Hour)
+	}()
+
+	entry, err := fetchAndStore(context.Background(), q, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(entry.Data) != lockTestPayload {
+		t.Errorf("entry = %s", entry.Data)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("upstream calls = %d, want 0", n)
+	}
+	if v, _ := f.get(key + ":lock"); v != "someone-else" {
+		t.Errorf("waiter touched the holder's lock: %q", v)
+	}
+}
+
+func TestFillLockWaiterFallsBackAfterTimeout(t *testing.T) {
+	f := useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(lockTestPayload))
+	q := weatherQuery{Location: "London"}
+	key := cacheKey("london")
+
+	// The holder never delivers
+	f.set(key+":lock", "someone-else", fillLockTTL)
+
+	start := time.Now()
+	entry, err := fetchAndStore(context.Background(), q, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < fillLockWait {
+		t.Errorf("fell back after %s, want at least %s", waited, fillLockWait)
+	}
+	if string(entry.Data) != lockTestPayload {
+		t.Errorf("entry = %s", entry.Data)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream calls = %d, want 1", n)
+	}
+}
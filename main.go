@@ -1,24 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"github.com/ulule/limiter/v3"
-	ginlimiter "github.com/ulule/limiter/v3/drivers/middleware/gin"
-	memory "github.com/ulule/limiter/v3/drivers/store/memory"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	apiKey       string
-	redisURL     string
-	redisAPIToken string
+	apiKey         string
+	redisURL       string
+	redisAPIToken  string
+	cacheKeyPrefix string
+	adminToken     string
 )
 
 func main() {
@@ -27,67 +33,427 @@ func main() {
 		fmt.Println("No .env file found")
 	}
 
-	apiKey = os.Getenv("VISUAL_CROSSING_API_KEY")
-	redisURL = os.Getenv("UPSTASH_REDIS_URL")
-	redisAPIToken = os.Getenv("UPSTASH_REDIS_TOKEN")
+	// Secrets can also come from mounted files (e.g. Docker secrets)
+	apiKey = loadSecret("VISUAL_CROSSING_API_KEY")
+	redisURL = loadSecret("UPSTASH_REDIS_URL")
+	redisAPIToken = loadSecret("UPSTASH_REDIS_TOKEN")
+	cacheKeyPrefix = os.Getenv("CACHE_KEY_PREFIX") // e.g. "prod:weather:", empty by default
+	adminToken = loadSecret("ADMIN_TOKEN")         // admin endpoints are disabled when empty
 
 	if apiKey == "" || redisURL == "" || redisAPIToken == "" {
 		panic("Missing .env values")
 	}
 
-	// Setup Gin router
-	r := gin.Default()
+	negativeCacheTTL = envDuration("NEGATIVE_CACHE_TTL", negativeCacheTTL)
+	negativeCacheAfter = envInt("NEGATIVE_CACHE_AFTER", negativeCacheAfter)
+	negativeCacheWindow = envDuration("NEGATIVE_CACHE_WINDOW", negativeCacheWindow)
+	dailyUpstreamLimit = int64(envInt("DAILY_UPSTREAM_LIMIT", 0))
+	inflightLimit = envInt("INFLIGHT_MAX", inflightLimit)
+	redisCooldown = envDuration("REDIS_COOLDOWN", redisCooldown)
+	setupHTTPClients()
+	setupProviders()
+	setupCacheControl()
+	setupPacer()
+	setupBreaker()
+	setupCityAllowlist()
+	setupAlerts()
+	quotaWarnPercent = int64(envInt("QUOTA_ALERT_PERCENT", int(quotaWarnPercent)))
+	redisOutageAfter = envDuration("REDIS_OUTAGE_ALERT_AFTER", redisOutageAfter)
 
-	// Rate limiting: 10 req per minute
-	rate, _ := limiter.NewRateFromFormatted("10-M")
-	store := memory.NewStore()
-	r.Use(ginlimiter.NewMiddleware(limiter.New(store, rate)))
+	r := newRouter()
 
-	r.GET("/weather/:city", getWeather)
+	startCacheAudit()
+	startHotCitiesWindow()
+	startMaintenanceSync()
 
 	r.Run(":51000")
 }
 
-func getWeather(c *gin.Context) {
-	city := c.Param("city")
+// newRouter wires up the middleware and routes. Configuration is read from
+// the environment, so call it after the globals are loaded.
+func newRouter() *gin.Engine {
+	r := gin.Default()
 
-	// Try getting from cache
-	if cached, err := redisGet(city); err == nil && cached != "" {
-		c.Data(http.StatusOK, "application/json", []byte(cached))
+	// Only honour X-Forwarded-For from these proxies (comma-separated CIDRs);
+	// by default no proxy is trusted and the socket address is the client IP
+	var trustedProxies []string
+	for _, n := range parseIPNets("TRUSTED_PROXIES", os.Getenv("TRUSTED_PROXIES")) {
+		trustedProxies = append(trustedProxies, n.String())
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		panic("Invalid TRUSTED_PROXIES: " + err.Error())
+	}
+
+	if setupTracing() {
+		r.Use(tracingMiddleware)
+	}
+	r.Use(requestTimeout())
+
+	if os.Getenv("REQUIRE_USER_AGENT") == "true" {
+		r.Use(requireUserAgent())
+	}
+
+	// Registered before the rate limiter so monitoring is never throttled
+	r.GET("/status", getStatus)
+
+	r.Use(rateLimiter())
+	r.Use(trackInflight)
+
+	observability := metricsAuth()
+
+	weather := r.Group("/weather", maintenanceGuard, cityAllowlistGuard)
+	if os.Getenv("STRICT_CITY_VALIDATION") == "true" {
+		weather.Use(strictCityValidation)
+	}
+	if disambiguate := setupDisambiguation(); disambiguate != nil {
+		weather.Use(disambiguate)
+	}
+	weather.GET("/:city", jsonOnly, getWeather)
+	weather.GET("/:city/now", schemaVersion, getCurrent)
+	weather.GET("/:city/history/aggregate", jsonOnly, getHistoryAggregate)
+	weather.GET("/:city/history/download", downloadHistory)
+	weather.GET("/:city/stream", schemaVersion, streamWeather)
+	weather.GET("/:city/hourly", schemaVersion, getHourly)
+	weather.GET("/:city/forecast", schemaVersion, getForecast)
+	weather.GET("/:city/card", jsonOnly, getCard)
+	weather.POST("/:city/snapshot", postSnapshot)
+	weather.GET("/:city/accuracy", jsonOnly, getAccuracy)
+	weather.GET("/:city/anomaly", jsonOnly, getAnomaly)
+	weather.GET("/zip/:code", jsonOnly, getWeatherByZip)
+	weather.POST("/average", schemaVersion, jsonOnly, postAverage)
+	r.GET("/validate", maintenanceGuard, cityAllowlistGuard, jsonOnly, validateCity)
+
+	r.GET("/weather/:city/debug", observability, adminAuth, jsonOnly, debugWeather)
+
+	r.GET("/metrics", observability, getMetrics)
+	r.GET("/usage", observability, getUsage)
+
+	admin := r.Group("/admin", observability, adminAuth)
+	admin.DELETE("/cache", flushCache)
+	admin.POST("/cache/audit", runCacheAudit)
+	admin.GET("/cache/stats", getCacheStats)
+	admin.GET("/cache/top", getTopCities)
+	admin.GET("/inflight", getInflight)
+	admin.GET("/config/cache-ttl", getCacheTTL)
+	admin.PUT("/config/cache-ttl", putCacheTTL)
+	admin.POST("/maintenance", enableMaintenance)
+	admin.DELETE("/maintenance", disableMaintenance)
+
+	return r
+}
+
+// loadSecret reads the named secret from the file in <name>_FILE when that is
+// set, falling back to the inline env var. A referenced file that can't be
+// read is a startup error rather than a silently empty secret.
+func loadSecret(name string) string {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return os.Getenv(name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("Cannot read %s_FILE: %v", name, err))
+	}
+	return strings.TrimRight(string(data), "\r\n")
+}
+
+func rateLimitReached(c *gin.Context) {
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+}
+
+func getWeather(c *gin.Context) {
+	lang, err := requestLang(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Not cached → fetch from Visual Crossing
-	url := fmt.Sprintf(
-		"https://weather.visualcrossing.com/VisualCrossingWebServices/rest/services/timeline/%s?unitGroup=metric&key=%s&contentType=json",
-		city, apiKey,
-	)
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	resp, err := http.Get(url)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch weather data"})
+	extra, err := parseUpstreamParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(resp.Body)
+	coords, err := parseCoordinateHint(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Cache for 12 hours
-	_ = redisSet(city, body, 12*time.Hour)
+	// The city name wins; the coordinates are only a hint for names Visual
+	// Crossing can't resolve, and the response says which one answered.
+	q := weatherQuery{Location: c.Param("city"), Lang: lang, MaxAge: maxAge, Extra: extra}
+	entry, err := fetchWeather(c.Request.Context(), q)
+	source := "city"
+	if errors.Is(err, errLocationNotFound) && coords != "" {
+		q.Location = coords
+		entry, err = fetchWeather(c.Request.Context(), q)
+		source = "coordinates"
+	}
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
 
 	// Return response
 	var parsed map[string]interface{}
-	json.Unmarshal(body, &parsed)
-	c.JSON(http.StatusOK, parsed)
+	json.Unmarshal(entry.Data, &parsed)
+	setDataAge(c, entry.FetchedAt)
+	c.Header("X-Location-Source", source)
+	respond(c, http.StatusOK, parsed)
+}
+
+// weatherQuery describes one Visual Crossing timeline request.
+type weatherQuery struct {
+	Location string
+	Start    string     // optional YYYY-MM-DD; defaults to the 15-day forecast
+	End      string     // optional YYYY-MM-DD, requires Start
+	Include  string     // optional comma-separated include= sections
+	Elements string     // optional comma-separated elements= list
+	Lang     string     // optional lang= for condition text, English when empty
+	Extra    url.Values // optional allowlisted vc.* passthrough params
+
+	// MaxAge and TTL are cache policy rather than upstream parameters:
+	// cached entries older than MaxAge are refetched (zero accepts any), and
+	// fresh fetches are stored for TTL (zero means defaultCacheTTL).
+	MaxAge time.Duration
+	TTL    time.Duration
+}
+
+// cacheTTL is how long a fresh fetch for q is cached.
+func (q weatherQuery) cacheTTL() time.Duration {
+	if q.TTL > 0 {
+		return q.TTL
+	}
+	return time.Duration(defaultCacheTTL.Load())
+}
+
+// keyOptions returns the cache key suffixes for the parts of q that change
+// the payload. English is the default so it adds no suffix, keeping keys
+// written before languages were supported valid.
+func (q weatherQuery) keyOptions() []string {
+	var opts []string
+	if q.Lang != "" && q.Lang != "en" {
+		opts = append(opts, "lang="+q.Lang)
+	}
+	return append(opts, passthroughKeyOptions(q.Extra)...)
+}
+
+// fetchWeather returns the raw Visual Crossing payload for a city query and
+// when it was fetched, serving it from the cache when possible and caching
+// fresh fetches for defaultCacheTTL (12h unless changed through PUT
+// /admin/config/cache-ttl).
+func fetchWeather(ctx context.Context, q weatherQuery) (*cacheEntry, error) {
+	recordCityRequest(q.Location)
+	return fetchLocation(ctx, q, cacheKey(q.Location, q.keyOptions()...))
+}
+
+// fetchLocation is fetchWeather for an arbitrary query cached under the
+// given key.
+func fetchLocation(ctx context.Context, q weatherQuery, key string) (*cacheEntry, error) {
+	// Try getting from cache
+	setStage(ctx, stageCache)
+	cacheCtx, span := tracer.Start(ctx, "cache.get", trace.WithAttributes(attribute.String("cache.key", key)))
+	entry, ok := readCache(cacheCtx, key)
+	span.SetAttributes(attribute.Bool("cache.hit", ok))
+	span.End()
+	if ok && (q.freshEnough(entry) || cacheOnly(ctx)) {
+		setStage(ctx, stageHandler)
+		recordCacheLookup(true)
+		return entry.orNotFound()
+	}
+	recordCacheLookup(false)
+	setStage(ctx, stageHandler)
+	if cacheOnly(ctx) {
+		return nil, errRateLimited
+	}
+
+	// Not cached → fetch from Visual Crossing, letting only one instance do
+	// so at a time
+	fresh, err := fetchAndStore(ctx, q, key)
+	if (errors.Is(err, errDailyLimit) || errors.Is(err, errBreakerOpen)) && ok && !entry.NotFound {
+		// Upstream unavailable to us: data older than max_age beats none
+		return entry, nil
+	}
+	return fresh, err
+}
+
+// fetchUpstream calls Visual Crossing directly, bypassing the cache.
+func fetchUpstream(ctx context.Context, q weatherQuery) (body []byte, err error) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "upstream.fetch", trace.WithAttributes(attribute.String("weather.location", q.Location)))
+	defer func() {
+		recordUpstreamCall(time.Since(start))
+		endSpan(span, err)
+	}()
+
+	resp, err := openUpstream(ctx, q)
+	defer setStage(ctx, stageHandler)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !completePayload(body) {
+		return nil, errIncompletePayload
+	}
+	return body, nil
+}
+
+// openUpstream spends one unit of the daily quota and returns Visual
+// Crossing's 200 response for q with the body unread; the caller closes it.
+// Transport errors and 5xx/429 answers count against the circuit breaker.
+func openUpstream(ctx context.Context, q weatherQuery) (*http.Response, error) {
+	setStage(ctx, stageUpstream)
+	if err := upstreamBreaker.allow(); err != nil {
+		return nil, err
+	}
+	if err := pacer.wait(ctx); err != nil {
+		return nil, err
+	}
+	if err := reserveUpstreamCall(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", timelineURL(q, apiKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := weatherClient.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			upstreamBreaker.record(err)
+		}
+		return nil, err
+	}
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		err := fmt.Errorf("visual crossing returned %s", resp.Status)
+		upstreamBreaker.record(err)
+		return nil, err
+	}
+	upstreamBreaker.record(nil)
+	if resp.StatusCode == http.StatusBadRequest {
+		// Unknown locations come back as a 400 with a plain-text reason
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if strings.Contains(strings.ToLower(string(msg)), "invalid location") {
+			return nil, errLocationNotFound
+		}
+		return nil, fmt.Errorf("visual crossing returned %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("visual crossing returned %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// errIncompletePayload means Visual Crossing's body was cut short or isn't
+// a timeline response; it must not be cached.
+var errIncompletePayload = errors.New("incomplete or malformed upstream payload")
+
+// completePayload reports whether body is valid JSON shaped like a timeline
+// response. A connection dropped mid-body can leave io.ReadAll with a prefix
+// that json.Unmarshal would partly accept, so check before caching.
+func completePayload(body []byte) bool {
+	if !json.Valid(body) {
+		return false
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(body, &top); err != nil {
+		return false
+	}
+	_, hasAddress := top["resolvedAddress"]
+	_, hasDays := top["days"]
+	return hasAddress || hasDays
+}
+
+// timelineURL builds the Visual Crossing timeline request for a query. The
+// key is a parameter so debug output can pass a placeholder instead.
+func timelineURL(q weatherQuery, key string) string {
+	path := url.PathEscape(q.Location)
+	if q.Start != "" {
+		path += "/" + q.Start
+		if q.End != "" {
+			path += "/" + q.End
+		}
+	}
+
+	params := url.Values{}
+	params.Set("unitGroup", "metric")
+	params.Set("key", key)
+	params.Set("contentType", "json")
+	if q.Include != "" {
+		params.Set("include", q.Include)
+	}
+	if q.Elements != "" {
+		params.Set("elements", q.Elements)
+	}
+	if q.Lang != "" {
+		params.Set("lang", q.Lang)
+	}
+	for name := range q.Extra {
+		params.Set(name, q.Extra.Get(name))
+	}
+
+	return "https://weather.visualcrossing.com/VisualCrossingWebServices/rest/services/timeline/" +
+		path + "?" + params.Encode()
 }
 
 // --- Upstash Redis REST helpers ---
 
-func redisGet(key string) (string, error) {
-	req, _ := http.NewRequest("GET", redisURL+"/get/"+key, nil)
+// keySegmentEscaper escapes ":" in the parts of a cacheKey, and "%" so the
+// escaping stays reversible. A city such as "London:lock" or
+// "_meta:usage:2024-03-01" then can't spell out a lock, counter or other
+// service key.
+var keySegmentEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// metaKeyCity namespaces the service's own keys. Escaped city names never
+// contain a bare "%", so no request can reach it.
+const metaKeyCity = "%meta"
+
+// cacheKey builds the Redis key for a city lookup. The configured prefix and
+// cacheSchemaVersion are prepended and any options are appended as
+// ":"-separated suffixes, so every key the service reads, writes or deletes
+// goes through here. City and options are escaped with keySegmentEscaper;
+// only suffixes the service appends itself, like ":lock", are not.
+func cacheKey(city string, opts ...string) string {
+	key := cacheKeyPrefix + cacheSchemaVersion + ":" + keySegmentEscaper.Replace(city)
+	for _, opt := range opts {
+		key += ":" + keySegmentEscaper.Replace(opt)
+	}
+	return key
+}
+
+// stateKey builds the key for service state such as the usage counter. It
+// sits under CACHE_KEY_PREFIX but outside cacheSchemaVersion: only cached
+// payloads change shape, and a deploy must not reset the daily count.
+func stateKey(parts ...string) string {
+	return cacheKeyPrefix + metaKeyCity + ":" + strings.Join(parts, ":")
+}
+
+// redisGet and redisSet put the key in the URL path, so it is escaped: a
+// city name may contain anything from "/" to "%".
+func redisGet(ctx context.Context, key string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", redisURL+"/get/"+url.PathEscape(key), nil)
+	if err != nil {
+		return "", err
+	}
 	req.Header.Set("Authorization", "Bearer "+redisAPIToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doRedis(req)
 	if err != nil {
 		return "", err
 	}
@@ -103,18 +469,117 @@ func redisGet(key string) (string, error) {
 	return out.Result, nil
 }
 
-func redisSet(key string, value []byte, ttl time.Duration) error {
-	// POST https://<url>/set/<key>?EX=<seconds>&value=<value>
-	req, _ := http.NewRequest("POST",
-		fmt.Sprintf("%s/set/%s?EX=%d&value=%s", redisURL, key, int(ttl.Seconds()), value),
-		nil,
+func redisSet(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	// POST https://<url>/set/<key>?EX=<seconds> with the value as the body,
+	// which unlike a query param survives payloads containing & or #
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		fmt.Sprintf("%s/set/%s?EX=%d", redisURL, url.PathEscape(key), int(ttl.Seconds())),
+		bytes.NewReader(value),
 	)
+	if err != nil {
+		return err
+	}
 	req.Header.Set("Authorization", "Bearer "+redisAPIToken)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doRedis(req)
 	if err != nil {
 		return err
 	}
 	resp.Body.Close()
 	return nil
 }
+
+// redisCommand runs a single Redis command through the Upstash REST API by
+// POSTing it as a JSON array, e.g. ["SCAN", "0", "MATCH", "prefix*"].
+func redisCommand(ctx context.Context, args ...interface{}) (json.RawMessage, error) {
+	payload, _ := json.Marshal(args)
+	req, err := http.NewRequestWithContext(ctx, "POST", redisURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+redisAPIToken)
+
+	resp, err := doRedis(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	var out struct {
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	if out.Error != "" {
+		return nil, errors.New(out.Error)
+	}
+	return out.Result, nil
+}
+
+// redisPipeline sends several commands in one round trip via the Upstash
+// /pipeline endpoint and returns their results in order.
+func redisPipeline(ctx context.Context, cmds [][]interface{}) ([]json.RawMessage, error) {
+	payload, _ := json.Marshal(cmds)
+	req, err := http.NewRequestWithContext(ctx, "POST", redisURL+"/pipeline", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+redisAPIToken)
+
+	resp, err := doRedis(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	var out []struct {
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+	results := make([]json.RawMessage, len(out))
+	for i, o := range out {
+		if o.Error != "" {
+			return nil, errors.New(o.Error)
+		}
+		results[i] = o.Result
+	}
+	return results, nil
+}
+
+// redisScan walks every key matching pattern using the SCAN cursor and calls
+// fn with each page of keys.
+func redisScan(ctx context.Context, pattern string, fn func(keys []string) error) error {
+	cursor := "0"
+	for {
+		raw, err := redisCommand(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", 100)
+		if err != nil {
+			return err
+		}
+		var page []json.RawMessage
+		if err := json.Unmarshal(raw, &page); err != nil || len(page) != 2 {
+			return fmt.Errorf("unexpected SCAN reply: %s", raw)
+		}
+		var keys []string
+		if err := json.Unmarshal(page[0], &cursor); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(page[1], &keys); err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
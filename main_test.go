@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCompletePayload(t *testing.T) {
+	tests := map[string]bool{
+		londonPayload:                                   true,
+		`{"days":[]}`:                                   true,
+		`{"resolvedAddress":"London"}`:                  true,
+		londonPayload[:len(londonPayload)/2]:            false,
+		`{"resolvedAddress":"London","days":[{"temp":1`: false,
+		`{}`:            false,
+		`[]`:            false,
+		`{"error":"x"}`: false,
+		``:              false,
+	}
+	for body, want := range tests {
+		if got := completePayload([]byte(body)); got != want {
+			t.Errorf("completePayload(%q) = %v, want %v", body, got, want)
+		}
+	}
+}
+
+func TestTruncatedPayloadIsRejectedAndNotCached(t *testing.T) {
+	f := useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(londonPayload[:30]))
+	r := testRouter(t)
+
+	for i := 1; i <= 2; i++ {
+		if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusBadGateway {
+			t.Errorf("request %d: status = %d, want 502", i, w.Code)
+		}
+	}
+	if _, ok := f.get(cacheKey("London")); ok {
+		t.Error("truncated payload was cached")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream calls = %d, want every request to retry", n)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceState is what POST /admin/maintenance stores. It is kept in
+// Redis so every instance picks it up, and mirrored in memory so checking it
+// doesn't cost a Redis call per request.
+type MaintenanceState struct {
+	Enabled    bool       `json:"enabled"`
+	Message    string     `json:"message,omitempty"`
+	RetryAfter int        `json:"retryAfter,omitempty"` // seconds
+	Until      *time.Time `json:"until,omitempty"`
+}
+
+const maintenanceDefaultFor = 24 * time.Hour
+
+var maintenance = struct {
+	sync.RWMutex
+	state MaintenanceState
+	// localOnly is set when enabling failed to reach Redis, so the poll
+	// doesn't take the missing key as "disabled" and drop our state.
+	localOnly bool
+}{}
+
+func maintenanceKey() string {
+	return stateKey("maintenance")
+}
+
+// startMaintenanceSync refreshes the in-memory flag from Redis every
+// MAINTENANCE_POLL (default 1m, "0" disables) so toggles made on another
+// instance apply here. Each poll is one Upstash command per instance, so
+// keep it long; the instance that handled the toggle applies it at once.
+func startMaintenanceSync() {
+	poll := envDuration("MAINTENANCE_POLL", time.Minute)
+	if poll <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(poll) {
+			syncMaintenance(context.Background())
+		}
+	}()
+}
+
+// syncMaintenance applies the state stored in Redis. A missing key means
+// maintenance is off, unless it was only enabled locally.
+func syncMaintenance(ctx context.Context) {
+	state, found, err := loadMaintenance(ctx)
+	if err != nil {
+		return
+	}
+
+	maintenance.Lock()
+	defer maintenance.Unlock()
+	if !found && maintenance.localOnly {
+		return
+	}
+	maintenance.state, maintenance.localOnly = state, false
+}
+
+func loadMaintenance(ctx context.Context) (MaintenanceState, bool, error) {
+	var state MaintenanceState
+	raw, err := redisGet(ctx, maintenanceKey())
+	if err != nil || raw == "" {
+		return state, false, err
+	}
+	err = json.Unmarshal([]byte(raw), &state)
+	return state, true, err
+}
+
+func setMaintenance(state MaintenanceState, localOnly bool) {
+	maintenance.Lock()
+	maintenance.state, maintenance.localOnly = state, localOnly
+	maintenance.Unlock()
+}
+
+// maintenanceGuard answers weather requests with a 503 while maintenance is
+// on. It is only attached to the weather routes, so admin and metrics keep
+// working.
+func maintenanceGuard(c *gin.Context) {
+	maintenance.RLock()
+	state := maintenance.state
+	maintenance.RUnlock()
+
+	if !state.Enabled || (state.Until != nil && time.Now().After(*state.Until)) {
+		c.Next()
+		return
+	}
+
+	if state.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(state.RetryAfter))
+	}
+	message := state.Message
+	if message == "" {
+		message = "The weather service is down for planned maintenance. Please try again later."
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "maintenance", "message": message})
+}
+
+// enableMaintenance turns maintenance mode on. The optional body sets the
+// message, the Retry-After seconds and how long it lasts ("duration", a Go
+// duration, default 24h) so a forgotten toggle eventually clears itself.
+func enableMaintenance(c *gin.Context) {
+	var req struct {
+		Message    string `json:"message"`
+		RetryAfter int    `json:"retryAfter"`
+		Duration   string `json:"duration"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	duration := maintenanceDefaultFor
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duration must be a positive Go duration, e.g. 2h"})
+			return
+		}
+		duration = d
+	}
+	if req.RetryAfter <= 0 {
+		req.RetryAfter = 300
+	}
+
+	until := time.Now().Add(duration)
+	state := MaintenanceState{
+		Enabled:    true,
+		Message:    req.Message,
+		RetryAfter: req.RetryAfter,
+		Until:      &until,
+	}
+	value, _ := json.Marshal(state)
+	_, err := redisCommand(c.Request.Context(), "SET", maintenanceKey(), string(value), "EX", int(duration.Seconds()))
+	if err != nil {
+		log.Printf("maintenance mode only enabled locally: %v", err)
+	}
+	setMaintenance(state, err != nil)
+
+	respond(c, http.StatusOK, state)
+}
+
+func disableMaintenance(c *gin.Context) {
+	if _, err := redisCommand(c.Request.Context(), "DEL", maintenanceKey()); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to clear maintenance mode in Redis"})
+		return
+	}
+	setMaintenance(MaintenanceState{}, false)
+
+	respond(c, http.StatusOK, MaintenanceState{})
+}
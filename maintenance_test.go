@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func resetMaintenance(t *testing.T) {
+	setMaintenance(MaintenanceState{}, false)
+	t.Cleanup(func() { setMaintenance(MaintenanceState{}, false) })
+}
+
+func TestMaintenanceToggle(t *testing.T) {
+	f := useFakeUpstash(t)
+	fakeUpstream(t, servePayload(`{"resolvedAddress":"London","days":[{}]}`))
+	withAdminToken(t, "secret")
+	resetMaintenance(t)
+	r := testRouter(t)
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusOK {
+		t.Fatalf("before: status = %d", w.Code)
+	}
+
+	if w := serve(r, "POST", "/admin/maintenance", admin); w.Code != http.StatusOK {
+		t.Fatalf("enable: status = %d %s", w.Code, w.Body)
+	}
+	if _, ok := f.get(maintenanceKey()); !ok {
+		t.Error("maintenance state not stored in Redis")
+	}
+	w := serve(r, "GET", "/weather/London", nil)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "300" {
+		t.Errorf("during: status = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	// Admin and monitoring keep working
+	if w := serve(r, "GET", "/metrics", nil); w.Code != http.StatusOK {
+		t.Errorf("/metrics during maintenance = %d", w.Code)
+	}
+
+	if w := serve(r, "DELETE", "/admin/maintenance", admin); w.Code != http.StatusOK {
+		t.Fatalf("disable: status = %d", w.Code)
+	}
+	if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusOK {
+		t.Errorf("after: status = %d", w.Code)
+	}
+}
+
+func TestMaintenanceSyncFollowsOtherInstances(t *testing.T) {
+	f := useFakeUpstash(t)
+	resetMaintenance(t)
+
+	f.set(maintenanceKey(), `{"enabled":true,"message":"upgrading"}`, 0)
+	syncMaintenance(context.Background())
+	if !maintenance.state.Enabled || maintenance.state.Message != "upgrading" {
+		t.Fatalf("state after remote enable = %+v", maintenance.state)
+	}
+
+	f.exec([]interface{}{"DEL", maintenanceKey()})
+	syncMaintenance(context.Background())
+	if maintenance.state.Enabled {
+		t.Error("remote disable not picked up")
+	}
+}
+
+func TestMaintenanceSyncKeepsLocalOnlyState(t *testing.T) {
+	f := useFakeUpstash(t)
+	fakeUpstream(t, servePayload(`{"resolvedAddress":"London","days":[{}]}`))
+	withAdminToken(t, "secret")
+	resetMaintenance(t)
+	r := testRouter(t)
+
+	// Redis is down while maintenance is enabled
+	working := redisClient
+	redisClient = &http.Client{Transport: handlerTransport{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})}}
+	w := serve(r, "POST", "/admin/maintenance", http.Header{"Authorization": {"Bearer secret"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable: status = %d %s", w.Code, w.Body)
+	}
+	redisClient = working
+	redisThrottledUntil.Store(0)
+
+	// Redis is back but never got the key; the poll must not drop our state
+	syncMaintenance(context.Background())
+	if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d after sync, want maintenance to stay on", w.Code)
+	}
+
+	// Once Redis holds a state again, it wins
+	f.set(maintenanceKey(), `{"enabled":false}`, 0)
+	syncMaintenance(context.Background())
+	if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusOK {
+		t.Errorf("status = %d after Redis state was restored: %s", w.Code, w.Body)
+	}
+}
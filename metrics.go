@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsAuth returns basic-auth middleware for /metrics and the admin routes
+// when METRICS_AUTH_USER and METRICS_AUTH_PASS are set. Without them it is a
+// no-op, leaving /metrics open for in-cluster scraping; the admin routes
+// still require the admin token either way.
+func metricsAuth() gin.HandlerFunc {
+	user, pass := os.Getenv("METRICS_AUTH_USER"), loadSecret("METRICS_AUTH_PASS")
+	if user == "" || pass == "" {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return gin.BasicAuth(gin.Accounts{user: pass})
+}
+
+// getMetrics serves the cache counters in the Prometheus text format.
+func getMetrics(c *gin.Context) {
+	s := currentCacheStats()
+	upstreamSeconds := time.Duration(cacheStats.upstreamNanos.Load()).Seconds()
+
+	c.Header("Content-Type", "text/plain; version=0.0.4")
+	c.Status(http.StatusOK)
+	writeMetric(c, "weather_cache_hits_total", "counter", "Cache lookups served from Redis.", s.Hits)
+	writeMetric(c, "weather_cache_misses_total", "counter", "Cache lookups that missed.", s.Misses)
+	writeMetric(c, "weather_upstream_requests_total", "counter", "Requests sent to Visual Crossing.", s.UpstreamCalls)
+	writeMetric(c, "weather_fetches_coalesced_total", "counter", "Cache misses served by a fetch already in flight.", s.FetchesCoalesced)
+	writeMetric(c, "weather_fetches_initiated_total", "counter", "Cache misses that started their own upstream fetch.", s.FetchesInitiated)
+	writeMetric(c, "weather_upstream_duration_seconds_total", "counter", "Time spent waiting on Visual Crossing.", upstreamSeconds)
+	writeMetric(c, "weather_upstream_queue_depth", "gauge", "Requests waiting for the upstream pacer.", upstreamQueue.Load())
+}
+
+func writeMetric(c *gin.Context, name, kind, help string, value interface{}) {
+	fmt.Fprintf(c.Writer, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+}
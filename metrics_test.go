@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func withAdminToken(t *testing.T, token string) {
+	old := adminToken
+	adminToken = token
+	t.Cleanup(func() { adminToken = old })
+}
+
+func basicAuth(user, pass string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+}
+
+func TestMetricsOpenWithoutCredentials(t *testing.T) {
+	withAdminToken(t, "secret")
+	r := testRouter(t)
+
+	w := serve(r, "GET", "/metrics", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "weather_cache_hits_total") {
+		t.Errorf("/metrics = %d %s, want open", w.Code, w.Body)
+	}
+	// The admin token is still required
+	if w := serve(r, "GET", "/admin/cache/stats", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("/admin/cache/stats without token = %d, want 401", w.Code)
+	}
+	if w := serve(r, "GET", "/admin/cache/stats", http.Header{"Authorization": {"Bearer secret"}}); w.Code != http.StatusOK {
+		t.Errorf("/admin/cache/stats with token = %d, want 200", w.Code)
+	}
+}
+
+func TestMetricsBasicAuth(t *testing.T) {
+	useFakeUpstash(t)
+	withAdminToken(t, "secret")
+	t.Setenv("METRICS_AUTH_USER", "prom")
+	t.Setenv("METRICS_AUTH_PASS", "scrape")
+	r := testRouter(t)
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		want   int
+	}{
+		{"metrics anonymous", "/metrics", nil, http.StatusUnauthorized},
+		{"metrics wrong password", "/metrics", http.Header{"Authorization": {basicAuth("prom", "nope")}}, http.StatusUnauthorized},
+		{"metrics authorized", "/metrics", http.Header{"Authorization": {basicAuth("prom", "scrape")}}, http.StatusOK},
+		{"usage authorized", "/usage", http.Header{"Authorization": {basicAuth("prom", "scrape")}}, http.StatusOK},
+		{"admin with token only", "/admin/cache/stats", http.Header{"X-Admin-Token": {"secret"}}, http.StatusUnauthorized},
+		{"admin with basic auth only", "/admin/cache/stats", http.Header{"Authorization": {basicAuth("prom", "scrape")}}, http.StatusUnauthorized},
+		{"admin with both", "/admin/cache/stats", http.Header{
+			"Authorization": {basicAuth("prom", "scrape")},
+			"X-Admin-Token": {"secret"},
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(r, "GET", tt.target, tt.header); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
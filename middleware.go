@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireUserAgent rejects requests whose User-Agent is missing or shorter
+// than USER_AGENT_MIN_LENGTH (default 1). Many scrapers omit the header.
+// It is only installed when REQUIRE_USER_AGENT=true so automated clients
+// aren't broken by default.
+func requireUserAgent() gin.HandlerFunc {
+	minLength := envInt("USER_AGENT_MIN_LENGTH", 1)
+
+	return func(c *gin.Context) {
+		if len(strings.TrimSpace(c.GetHeader("User-Agent"))) < minLength {
+			if gin.IsDebugging() {
+				log.Printf("rejected request without User-Agent from %s", c.ClientIP())
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "a User-Agent header is required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// longLivedRoutes are exempt from requestTimeout because they stay open by
+// design; they apply their own per-operation timeouts.
+var longLivedRoutes = map[string]bool{
+	"/weather/:city/stream":           true,
+	"/weather/:city/history/download": true,
+}
+
+// requestTimeout gives every request a deadline (REQUEST_TIMEOUT, default
+// 15s). Upstream and Redis calls use the request context, so they are
+// cancelled when it expires and the handler answers via fetchFailed. If a
+// handler still returns without writing anything, we send the 504 here.
+func requestTimeout() gin.HandlerFunc {
+	timeout := envDuration("REQUEST_TIMEOUT", 15*time.Second)
+
+	return func(c *gin.Context) {
+		if longLivedRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "request timed out"})
+		}
+	}
+}
+
+// fetchFailed reports a failed weather fetch: 404 for an unknown location,
+// 503 once the daily upstream budget is spent, 504 if the request ran out of
+// time, 502 for any other upstream problem.
+func fetchFailed(c *gin.Context, err error) {
+	if errors.Is(err, errLocationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "location not found"})
+		return
+	}
+	if errors.Is(err, errRateLimited) {
+		c.Writer.Header().Del("Warning")
+		rateLimitReached(c)
+		return
+	}
+	if errors.Is(err, errUpstreamBusy) {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "upstream is busy, try again shortly"})
+		return
+	}
+	if errors.Is(err, errBreakerOpen) {
+		c.Header("Retry-After", strconv.Itoa(int(upstreamBreaker.retryAfter().Seconds())+1))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "upstream is failing, try again shortly"})
+		return
+	}
+	if errors.Is(err, errDailyLimit) {
+		c.Header("Retry-After", strconv.Itoa(int(untilMidnightUTC().Seconds())+1))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "daily upstream limit reached, only cached data is available"})
+		return
+	}
+	if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "request timed out"})
+		return
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch weather data"})
+}
+
+// cityNamePattern allows letters (any script, with combining marks), spaces,
+// hyphens, apostrophes, commas and periods, e.g. "St. John's" or
+// "Washington, DC". It must start with a letter, optionally after an
+// apostrophe as in "'s-Hertogenbosch".
+var cityNamePattern = regexp.MustCompile(`^['’]?[\p{L}\p{M}][\p{L}\p{M} '’.,-]{0,99}$`)
+
+// strictCityValidation rejects :city params that don't look like a place
+// name before they reach Visual Crossing. It is opt-in via
+// STRICT_CITY_VALIDATION=true because coordinates ("51.5,-0.1") and postal
+// codes are valid Visual Crossing locations that this pattern refuses.
+func strictCityValidation(c *gin.Context) {
+	if city := c.Param("city"); city != "" && !cityNamePattern.MatchString(city) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid city name"})
+		return
+	}
+	c.Next()
+}
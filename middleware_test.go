@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireUserAgent(t *testing.T) {
+	t.Setenv("USER_AGENT_MIN_LENGTH", "3")
+	r := gin.New()
+	r.Use(requireUserAgent())
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+
+	tests := []struct {
+		name      string
+		userAgent []string
+		want      int
+	}{
+		{"missing", nil, http.StatusBadRequest},
+		{"empty", []string{""}, http.StatusBadRequest},
+		{"blank", []string{"   "}, http.StatusBadRequest},
+		{"too short", []string{"ab"}, http.StatusBadRequest},
+		{"present", []string{"curl/8.5.0"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.userAgent != nil {
+				header["User-Agent"] = tt.userAgent
+			}
+			if w := serve(r, "GET", "/ping", header); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestTimeoutCancelsUpstream(t *testing.T) {
+	useFakeUpstash(t)
+	t.Setenv("REQUEST_TIMEOUT", "200ms")
+	cancelled := make(chan bool, 1)
+	fakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			cancelled <- true
+		case <-time.After(5 * time.Second):
+			cancelled <- false
+			servePayload(`{"resolvedAddress":"London","days":[{}]}`)(w, r)
+		}
+	})
+	r := testRouter(t)
+
+	start := time.Now()
+	w := serve(r, "GET", "/weather/London", nil)
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504: %s", w.Code, w.Body)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("answered after %s, want shortly after the 200ms timeout", took)
+	}
+	select {
+	case ok := <-cancelled:
+		if !ok {
+			t.Error("upstream call ran to completion instead of being cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Error("upstream was never called")
+	}
+}
+
+func TestCityNamePattern(t *testing.T) {
+	valid := []string{
+		"London",
+		"St. John's",
+		"Washington, DC",
+		"Stratford-upon-Avon",
+		"'s-Hertogenbosch",
+		"’s-Gravenhage",
+		"São Paulo",
+		"Zürich",
+		"Москва",
+		"東京",
+		"Đà Nẵng",
+	}
+	garbage := []string{
+		"",
+		"51.5,-0.1",
+		"10115",
+		"-London",
+		"''London",
+		"'",
+		"London; DROP TABLE",
+		"<script>",
+		"new\nyork",
+		"../etc/passwd",
+		strings.Repeat("a", 101),
+	}
+	for _, city := range valid {
+		if !cityNamePattern.MatchString(city) {
+			t.Errorf("%q rejected", city)
+		}
+	}
+	for _, city := range garbage {
+		if cityNamePattern.MatchString(city) {
+			t.Errorf("%q accepted", city)
+		}
+	}
+}
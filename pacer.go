@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errUpstreamBusy means the pacer's queue would make a request wait longer
+// than UPSTREAM_QUEUE_TIMEOUT for its turn.
+var errUpstreamBusy = errors.New("too many queued upstream requests")
+
+// upstreamPacer is a token bucket in front of Visual Crossing, implemented
+// as a GCRA: tat is the theoretical arrival time of the next request, and a
+// request may go once now is within burst intervals of it. Requests that
+// can't go yet sleep in line; nil means pacing is off.
+type upstreamPacer struct {
+	interval time.Duration // 1 / UPSTREAM_RATE
+	burst    int
+	maxWait  time.Duration
+
+	mu  sync.Mutex
+	tat time.Time
+}
+
+var (
+	pacer         *upstreamPacer
+	upstreamQueue atomic.Int64 // requests currently sleeping in the pacer
+)
+
+// setupPacer enables pacing when UPSTREAM_RATE (requests per second, e.g.
+// "5" or "0.5") is set. UPSTREAM_BURST (default 1) requests may go back to
+// back, and a request queued longer than UPSTREAM_QUEUE_TIMEOUT (default
+// 2s) fails with a 503 instead.
+func setupPacer() {
+	raw := os.Getenv("UPSTREAM_RATE")
+	if raw == "" {
+		return
+	}
+	rate, err := strconv.ParseFloat(raw, 64)
+	if err != nil || rate <= 0 {
+		panic("Invalid UPSTREAM_RATE: " + raw)
+	}
+	pacer = &upstreamPacer{
+		interval: time.Duration(float64(time.Second) / rate),
+		burst:    envInt("UPSTREAM_BURST", 1),
+		maxWait:  envDuration("UPSTREAM_QUEUE_TIMEOUT", 2*time.Second),
+	}
+	if pacer.burst < 1 {
+		pacer.burst = 1
+	}
+}
+
+// wait blocks until the request may go upstream. The slot is claimed up
+// front, so the wait is known before sleeping and a request that would
+// exceed maxWait is refused without queueing at all.
+func (p *upstreamPacer) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	tat := p.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	delay := tat.Sub(now) - time.Duration(p.burst-1)*p.interval
+	if delay > p.maxWait {
+		p.mu.Unlock()
+		return errUpstreamBusy
+	}
+	p.tat = tat.Add(p.interval)
+	p.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	upstreamQueue.Add(1)
+	defer upstreamQueue.Add(-1)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPacerLimitsRequestRate(t *testing.T) {
+	p := &upstreamPacer{interval: 50 * time.Millisecond, burst: 1, maxWait: time.Second}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.wait(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Five requests at 20/s: the first goes at once, the last 200ms later
+	if took := time.Since(start); took < 190*time.Millisecond || took > 400*time.Millisecond {
+		t.Errorf("5 requests took %s, want about 200ms", took)
+	}
+}
+
+func TestPacerBurst(t *testing.T) {
+	p := &upstreamPacer{interval: time.Second, burst: 3, maxWait: 10 * time.Second}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := p.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if took := time.Since(start); took > 50*time.Millisecond {
+		t.Errorf("a burst of 3 waited %s, want no wait", took)
+	}
+}
+
+func TestPacerRefusesLongQueues(t *testing.T) {
+	p := &upstreamPacer{interval: 100 * time.Millisecond, burst: 1, maxWait: 150 * time.Millisecond}
+	ctx := context.Background()
+
+	if err := p.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- p.wait(ctx) }() // queued for 100ms
+
+	time.Sleep(20 * time.Millisecond)
+	if depth := upstreamQueue.Load(); depth != 1 {
+		t.Errorf("queue depth = %d, want 1", depth)
+	}
+	if err := p.wait(ctx); !errors.Is(err, errUpstreamBusy) {
+		t.Errorf("third request: err = %v, want errUpstreamBusy", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("queued request: %v", err)
+	}
+	if depth := upstreamQueue.Load(); depth != 0 {
+		t.Errorf("queue depth after = %d, want 0", depth)
+	}
+}
+
+func TestPacerGivesUpWithTheRequest(t *testing.T) {
+	p := &upstreamPacer{interval: time.Second, burst: 1, maxWait: 5 * time.Second}
+	p.wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's", err)
+	}
+}
+
+func TestNilPacerDoesNotWait(t *testing.T) {
+	var p *upstreamPacer
+	if err := p.wait(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
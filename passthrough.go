@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// passthroughPrefix marks query params forwarded verbatim to Visual
+// Crossing, e.g. ?vc.maxStations=5 becomes maxStations=5 upstream.
+const passthroughPrefix = "vc."
+
+// passthroughParams are the only Visual Crossing params a client may set
+// this way. Anything we set ourselves (key, unitGroup, contentType, include,
+// elements, lang) is deliberately absent.
+var passthroughParams = map[string]bool{
+	"maxStations":         true,
+	"maxDistance":         true,
+	"elevationDifference": true,
+	"locationNames":       true,
+	"iconSet":             true,
+	"timezone":            true,
+	"forecastBasisDate":   true,
+	"forecastBasisDay":    true,
+}
+
+const maxPassthroughValueLength = 100
+
+// passthroughValuePattern is the charset forwarded values may use: enough
+// for numbers, dates and timezones like "America/New_York" or "Etc/GMT+5".
+// The values also end up in cache keys, so anything else is refused.
+var passthroughValuePattern = regexp.MustCompile(fmt.Sprintf(`^[A-Za-z0-9._:/+-]{1,%d}$`, maxPassthroughValueLength))
+
+// parseUpstreamParams collects the vc.* query params, rejecting any name
+// not in passthroughParams and any value outside passthroughValuePattern.
+func parseUpstreamParams(c *gin.Context) (url.Values, error) {
+	var extra url.Values
+	for name, values := range c.Request.URL.Query() {
+		if !strings.HasPrefix(name, passthroughPrefix) {
+			continue
+		}
+		param := strings.TrimPrefix(name, passthroughPrefix)
+		if !passthroughParams[param] {
+			return nil, fmt.Errorf("%s cannot be forwarded to Visual Crossing", name)
+		}
+		if len(values) != 1 || !passthroughValuePattern.MatchString(values[0]) {
+			return nil, fmt.Errorf("%s must be given once, with at most %d letters, digits or ._:/+-", name, maxPassthroughValueLength)
+		}
+		if extra == nil {
+			extra = url.Values{}
+		}
+		extra.Set(param, values[0])
+	}
+	return extra, nil
+}
+
+// passthroughKeyOptions renders extra as cache key options in a stable
+// order, so the same params in any order share one entry.
+func passthroughKeyOptions(extra url.Values) []string {
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	opts := make([]string, 0, len(names))
+	for _, name := range names {
+		opts = append(opts, passthroughPrefix+name+"="+extra.Get(name))
+	}
+	return opts
+}
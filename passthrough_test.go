@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPassthroughParams(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"vc.maxStations=5", http.StatusOK},
+		{"vc.timezone=America/New_York", http.StatusOK},
+		{"vc.timezone=Etc/GMT%2B5", http.StatusOK},
+		{"vc.forecastBasisDate=2024-05-01&vc.maxDistance=50000.5", http.StatusOK},
+		{"vc.key=mine", http.StatusBadRequest},
+		{"vc.unitGroup=us", http.StatusBadRequest},
+		{"vc.maxStations=1&vc.maxStations=2", http.StatusBadRequest},
+		{"vc.timezone=a%3Fb", http.StatusBadRequest},
+		{"vc.timezone=x%25", http.StatusBadRequest},
+		{"vc.iconSet=icons%201", http.StatusBadRequest},
+		{"vc.locationNames=a%26key%3Dx", http.StatusBadRequest},
+		{"vc.iconSet=", http.StatusBadRequest},
+		{"vc.iconSet=" + strings.Repeat("a", maxPassthroughValueLength+1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := serve(r, "GET", "/weather/London?"+tt.query, nil); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.query, w.Code, tt.want, w.Body)
+		}
+	}
+}
+
+func TestPassthroughIsForwardedAndCached(t *testing.T) {
+	f := useFakeUpstash(t)
+	var mu sync.Mutex
+	var seen []url.Values
+	calls := fakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.Query())
+		mu.Unlock()
+		servePayload(londonPayload)(w, r)
+	})
+	r := testRouter(t)
+
+	for i := 0; i < 2; i++ {
+		if w := serve(r, "GET", "/weather/London?vc.timezone=America/New_York", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i+1, w.Code)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream calls = %d, want the second request cached", n)
+	}
+	if len(seen) > 0 && seen[0].Get("timezone") != "America/New_York" {
+		t.Errorf("upstream timezone = %q", seen[0].Get("timezone"))
+	}
+	if _, ok := f.get(cacheKey("London", "vc.timezone=America/New_York")); !ok {
+		t.Errorf("entry not stored under its key; have %v", f.keys())
+	}
+}
+
+func TestRedisKeysAreEscaped(t *testing.T) {
+	f := useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	for _, city := range []string{"100%", "a?b", "x#y"} {
+		target := "/weather/" + url.PathEscape(city)
+		for i := 0; i < 2; i++ {
+			if w := serve(r, "GET", target, nil); w.Code != http.StatusOK {
+				t.Fatalf("%q request %d: status = %d: %s", city, i+1, w.Code, w.Body)
+			}
+		}
+		if _, ok := f.get(cacheKey(city)); !ok {
+			t.Errorf("%q not stored under its key; have %v", city, f.keys())
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("upstream calls = %d, want one per city", n)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "strings"
+
+// precipLabels names Visual Crossing's preciptype values, in the order they
+// are listed in a summary; the more hazardous types come first.
+var precipLabels = []struct{ kind, label string }{
+	{"freezingrain", "freezing rain"},
+	{"ice", "ice"},
+	{"snow", "snow"},
+	{"rain", "rain"},
+}
+
+// precipSummary describes the expected precipitation, e.g. "Snow likely" or
+// "Slight chance of rain". Likelihood comes from prob (percent); when prob
+// is unknown only the type is named. An empty types list means Visual
+// Crossing expects none.
+func precipSummary(types []string, prob *float64) string {
+	present := map[string]bool{}
+	for _, t := range types {
+		present[strings.ToLower(t)] = true
+	}
+	var labels []string
+	for _, p := range precipLabels {
+		if present[p.kind] {
+			labels = append(labels, p.label)
+		}
+	}
+
+	var what string
+	switch len(labels) {
+	case 0:
+		return "No precipitation expected"
+	case 1:
+		what = labels[0]
+	case 2:
+		what = labels[0] + " and " + labels[1]
+	default:
+		what = "mixed precipitation"
+	}
+
+	switch {
+	case prob == nil:
+		return capitalize(what)
+	case *prob >= 60:
+		return capitalize(what) + " likely"
+	case *prob >= 30:
+		return capitalize(what) + " possible"
+	default:
+		return "Slight chance of " + what
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestPrecipSummary(t *testing.T) {
+	pct := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name  string
+		types []string
+		prob  *float64
+		want  string
+	}{
+		{"no precip, null types", nil, pct(0), "No precipitation expected"},
+		{"no precip, empty types", []string{}, nil, "No precipitation expected"},
+		{"rain likely", []string{"rain"}, pct(80), "Rain likely"},
+		{"rain possible", []string{"rain"}, pct(45), "Rain possible"},
+		{"slight chance of rain", []string{"rain"}, pct(10), "Slight chance of rain"},
+		{"snow likely", []string{"snow"}, pct(60), "Snow likely"},
+		{"snow, unknown probability", []string{"Snow"}, nil, "Snow"},
+		{"rain and snow, hazard first", []string{"rain", "snow"}, pct(70), "Snow and rain likely"},
+		{"freezing rain possible", []string{"freezingrain"}, pct(30), "Freezing rain possible"},
+		{"mixed", []string{"rain", "snow", "ice"}, pct(90), "Mixed precipitation likely"},
+		{"slight chance of mixed", []string{"rain", "snow", "freezingrain"}, pct(5), "Slight chance of mixed precipitation"},
+		{"unknown type only", []string{"hail"}, pct(90), "No precipitation expected"},
+	}
+	for _, tt := range tests {
+		if got := precipSummary(tt.types, tt.prob); got != tt.want {
+			t.Errorf("%s: precipSummary = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parsePrecision reads ?precision=, the number of decimal places (0-2) to
+// round numeric fields to. It returns -1 when the client didn't ask.
+func parsePrecision(c *gin.Context) (int, error) {
+	raw := c.Query("precision")
+	if raw == "" {
+		return -1, nil
+	}
+	places, err := strconv.Atoi(raw)
+	if err != nil || places < 0 || places > 2 {
+		return 0, errors.New("precision must be 0, 1 or 2")
+	}
+	return places, nil
+}
+
+// roundFloats rounds every float64 reachable from v (through pointers,
+// structs, slices and maps) to the given number of decimal places in place.
+// v must be a pointer. A negative places leaves everything untouched.
+func roundFloats(v interface{}, places int) {
+	if places < 0 {
+		return
+	}
+	roundValue(reflect.ValueOf(v), math.Pow10(places))
+}
+
+func roundValue(v reflect.Value, scale float64) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		elem := v.Elem()
+		if v.Kind() == reflect.Interface && elem.Kind() == reflect.Float64 {
+			// Values inside interfaces aren't addressable; swap in a rounded copy.
+			v.Set(reflect.ValueOf(math.Round(elem.Float()*scale) / scale))
+			return
+		}
+		roundValue(elem, scale)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				roundValue(v.Field(i), scale)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			roundValue(v.Index(i), scale)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if iter.Value().Kind() == reflect.Interface && iter.Value().Elem().Kind() == reflect.Float64 {
+				v.SetMapIndex(iter.Key(), reflect.ValueOf(math.Round(iter.Value().Elem().Float()*scale)/scale))
+				continue
+			}
+			roundValue(iter.Value(), scale)
+		}
+	case reflect.Float64:
+		if v.CanSet() {
+			v.SetFloat(math.Round(v.Float()*scale) / scale)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRoundFloats(t *testing.T) {
+	tests := []struct {
+		places int
+		want   float64
+	}{
+		{-1, 23.456},
+		{0, 23},
+		{1, 23.5},
+		{2, 23.46},
+	}
+	for _, tt := range tests {
+		temp := 23.456
+		resp := &CurrentResponse{Current: CurrentConditions{Temp: &temp, PrecipType: []string{"rain"}}}
+		raw := map[string]interface{}{"temp": 23.456, "days": []interface{}{map[string]interface{}{"tempmax": 23.456}}}
+
+		roundFloats(resp, tt.places)
+		roundFloats(&raw, tt.places)
+
+		if *resp.Current.Temp != tt.want {
+			t.Errorf("precision %d: struct temp = %v, want %v", tt.places, *resp.Current.Temp, tt.want)
+		}
+		if raw["temp"] != tt.want {
+			t.Errorf("precision %d: map temp = %v, want %v", tt.places, raw["temp"], tt.want)
+		}
+		day := raw["days"].([]interface{})[0].(map[string]interface{})
+		if day["tempmax"] != tt.want {
+			t.Errorf("precision %d: nested tempmax = %v, want %v", tt.places, day["tempmax"], tt.want)
+		}
+	}
+}
+
+func TestPrecisionValidation(t *testing.T) {
+	r := renderRouter(func() interface{} { return &CurrentResponse{} })
+	for query, want := range map[string]int{
+		"":              http.StatusOK,
+		"?precision=0":  http.StatusOK,
+		"?precision=2":  http.StatusOK,
+		"?precision=3":  http.StatusBadRequest,
+		"?precision=-1": http.StatusBadRequest,
+		"?precision=x":  http.StatusBadRequest,
+	} {
+		if w := serve(r, "GET", "/v"+query, nil); w.Code != want {
+			t.Errorf("%q: status = %d, want %d", query, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Provider is a source of current conditions normalised into our typed
+// schema, so clients see the same shape whichever provider answered.
+type Provider interface {
+	Name() string
+	Current(ctx context.Context, q weatherQuery) (*CurrentResponse, error)
+	// Ping checks the provider is reachable without spending quota.
+	Ping(ctx context.Context) error
+}
+
+var (
+	primaryProvider  Provider = visualCrossingProvider{}
+	fallbackProvider Provider // nil unless FALLBACK_PROVIDER is set
+)
+
+// setupProviders enables the optional fallback provider. The only one
+// available today is "open-meteo", which needs no API key.
+func setupProviders() {
+	switch name := os.Getenv("FALLBACK_PROVIDER"); name {
+	case "":
+	case "open-meteo":
+		fallbackProvider = openMeteoProvider{}
+	default:
+		panic("Unknown FALLBACK_PROVIDER: " + name)
+	}
+}
+
+// currentConditions asks the primary provider and, if that fails (including
+// when Visual Crossing rate-limits us), the fallback provider when one is
+// configured. Only the typed endpoints can fall back; the raw passthrough is
+// Visual Crossing's own payload and has nothing to normalise into.
+func currentConditions(ctx context.Context, q weatherQuery) (*CurrentResponse, error) {
+	resp, err := primaryProvider.Current(ctx, q)
+	if err != nil && !errors.Is(err, errLocationNotFound) && !errors.Is(err, errRateLimited) && fallbackProvider != nil {
+		log.Printf("%s failed for %q, trying %s: %v", primaryProvider.Name(), q.Location, fallbackProvider.Name(), err)
+		resp, err = fallbackProvider.Current(ctx, q)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cur := &resp.Current
+	cur.setDaytime()
+	cur.FeelsLikeSource = fillFeelsLike(&cur.FeelsLike, cur.Temp, cur.Humidity, cur.WindSpeed)
+	return resp, nil
+}
+
+// visualCrossingProvider serves current conditions from the cached
+// Visual Crossing timeline.
+type visualCrossingProvider struct{}
+
+func (visualCrossingProvider) Name() string { return "visual-crossing" }
+
+// Ping calls the timeline API without a key: Visual Crossing rejects it
+// with a 4xx, which proves it is up and costs nothing.
+func (visualCrossingProvider) Ping(ctx context.Context) error {
+	return pingURL(ctx, "https://weather.visualcrossing.com/VisualCrossingWebServices/rest/services/timeline/London")
+}
+
+func (p visualCrossingProvider) Current(ctx context.Context, q weatherQuery) (*CurrentResponse, error) {
+	entry, err := fetchWeather(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload weatherPayload
+	if err := json.Unmarshal(entry.Data, &payload); err != nil {
+		return nil, err
+	}
+	if payload.CurrentConditions == nil {
+		return nil, errors.New("no current conditions in payload")
+	}
+	// Only Visual Crossing reports preciptype, so only it gets a summary
+	cur := payload.CurrentConditions
+	cur.PrecipSummary = precipSummary(cur.PrecipType, cur.PrecipProb)
+
+	return &CurrentResponse{
+		Location:  payload.ResolvedAddress,
+		Timezone:  payload.Timezone,
+		Current:   *cur,
+		Source:    p.Name(),
+		FetchedAt: entry.FetchedAt,
+	}, nil
+}
+
+// openMeteoProvider resolves the location with the Open-Meteo geocoder and
+// then reads its current weather. Units already match our metric base
+// except visibility, which Open-Meteo reports in metres. Condition text is
+// always English.
+type openMeteoProvider struct{}
+
+func (openMeteoProvider) Name() string { return "open-meteo" }
+
+func (openMeteoProvider) Ping(ctx context.Context) error {
+	return pingURL(ctx, "https://geocoding-api.open-meteo.com/v1/search?count=1&name=London")
+}
+
+func (p openMeteoProvider) Current(ctx context.Context, q weatherQuery) (*CurrentResponse, error) {
+	results, err := geocode(ctx, q.Location, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("open-meteo could not resolve %q: %w", q.Location, errLocationNotFound)
+	}
+	place := results[0]
+
+	var forecast struct {
+		Timezone string `json:"timezone"`
+		Current  struct {
+			Time        string   `json:"time"`
+			Temp        *float64 `json:"temperature_2m"`
+			FeelsLike   *float64 `json:"apparent_temperature"`
+			Humidity    *float64 `json:"relative_humidity_2m"`
+			WindSpeed   *float64 `json:"wind_speed_10m"`
+			WindDir     *float64 `json:"wind_direction_10m"`
+			Pressure    *float64 `json:"surface_pressure"`
+			UVIndex     *float64 `json:"uv_index"`
+			Visibility  *float64 `json:"visibility"`
+			Precip      *float64 `json:"precipitation"`
+			WeatherCode *int     `json:"weather_code"`
+		} `json:"current"`
+	}
+	forecastURL := fmt.Sprintf(
+		"https://api.open-meteo.com/v1/forecast?latitude=%f&longitude=%f&timezone=auto&current=%s",
+		place.Latitude, place.Longitude,
+		"temperature_2m,apparent_temperature,relative_humidity_2m,wind_speed_10m,wind_direction_10m,surface_pressure,uv_index,visibility,precipitation,weather_code",
+	)
+	if err := getJSON(ctx, forecastURL, &forecast); err != nil {
+		return nil, err
+	}
+
+	cur := forecast.Current
+	convert(cur.Visibility, func(m float64) float64 { return m / 1000 })
+	conditions := ""
+	if cur.WeatherCode != nil {
+		conditions = wmoConditions[*cur.WeatherCode]
+	}
+
+	return &CurrentResponse{
+		Location: place.label(),
+		Timezone: forecast.Timezone,
+		Current: CurrentConditions{
+			Datetime:   cur.Time,
+			Temp:       cur.Temp,
+			FeelsLike:  cur.FeelsLike,
+			Humidity:   cur.Humidity,
+			WindSpeed:  cur.WindSpeed,
+			WindDir:    cur.WindDir,
+			Pressure:   cur.Pressure,
+			UVIndex:    cur.UVIndex,
+			Visibility: cur.Visibility,
+			Precip:     cur.Precip,
+			Conditions: conditions,
+		},
+		Source:    p.Name(),
+		FetchedAt: time.Now(),
+	}, nil
+}
+
+// wmoConditions maps WMO weather interpretation codes, as used by
+// Open-Meteo, to Visual Crossing style condition text.
+var wmoConditions = map[int]string{
+	0: "Clear", 1: "Mainly clear", 2: "Partially cloudy", 3: "Overcast",
+	45: "Fog", 48: "Freezing fog",
+	51: "Light drizzle", 53: "Drizzle", 55: "Heavy drizzle",
+	56: "Freezing drizzle", 57: "Freezing drizzle",
+	61: "Light rain", 63: "Rain", 65: "Heavy rain",
+	66: "Freezing rain", 67: "Freezing rain",
+	71: "Light snow", 73: "Snow", 75: "Heavy snow", 77: "Snow grains",
+	80: "Rain showers", 81: "Rain showers", 82: "Heavy rain showers",
+	85: "Snow showers", 86: "Heavy snow showers",
+	95: "Thunderstorm", 96: "Thunderstorm with hail", 99: "Thunderstorm with hail",
+}
+
+// getJSON GETs rawURL with the weather client and decodes a 200 response.
+func getJSON(ctx context.Context, rawURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := weatherClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", rawURL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dailyUpstreamLimit caps Visual Crossing requests per UTC day across all
+// instances (DAILY_UPSTREAM_LIMIT, 0 = unlimited). Once reached, only cached
+// data is served until midnight UTC. Requests are counted either way so
+// /usage always reports real numbers.
+var dailyUpstreamLimit int64
+
+var errDailyLimit = errors.New("daily upstream request limit reached")
+
+// quotaWarnPercent is the share of dailyUpstreamLimit, in percent, at which
+// a quota_warning alert goes out (QUOTA_ALERT_PERCENT, default 80).
+var quotaWarnPercent int64 = 80
+
+func usageKey(day time.Time) string {
+	return stateKey("usage", day.UTC().Format("2006-01-02"))
+}
+
+// untilMidnightUTC is how long until the daily counter rolls over.
+func untilMidnightUTC() time.Duration {
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return midnight.Sub(now)
+}
+
+// localUsage mirrors today's count in memory. Calls made while Redis can't
+// be reached are counted here and added to the Redis counter once it
+// answers again, so an Upstash outage or 429 can't lift the cap.
+var localUsage struct {
+	sync.Mutex
+	day      string
+	count    int64 // best known count for day
+	unsynced int64 // calls not yet added to the Redis counter
+}
+
+// reserveUpstreamCall counts one upstream request against today's budget,
+// returning errDailyLimit once it is spent. Without Redis the count goes on
+// in memory from the last value Redis reported; each instance then enforces
+// the limit on its own view, so the cap can only tighten, never lift.
+func reserveUpstreamCall(ctx context.Context) error {
+	now := time.Now()
+	day := now.UTC().Format("2006-01-02")
+	localUsage.Lock()
+	if localUsage.day != day {
+		localUsage.day, localUsage.count, localUsage.unsynced = day, 0, 0
+	}
+	incr := localUsage.unsynced + 1
+	localUsage.unsynced = 0
+	localUsage.Unlock()
+
+	key := usageKey(now)
+	raw, err := redisCommand(ctx, "INCRBY", key, incr)
+	var count int64
+	if err == nil {
+		err = json.Unmarshal(raw, &count)
+	}
+
+	localUsage.Lock()
+	if err != nil {
+		localUsage.unsynced += incr
+		localUsage.count++
+		count, incr = localUsage.count, 1
+	} else if count > localUsage.count {
+		localUsage.count = count
+	}
+	localUsage.Unlock()
+
+	if err == nil && count == incr {
+		// Keep yesterday's counter around briefly for /usage, then let it go
+		_, _ = redisCommand(ctx, "EXPIRE", key, int((48 * time.Hour).Seconds()))
+	}
+	if dailyUpstreamLimit <= 0 {
+		return nil
+	}
+	if err != nil {
+		log.Printf("daily upstream counter unavailable, counting locally: %v", err)
+	}
+
+	// Alert when this call crossed a threshold, so one instance alerts once
+	// per threshold per day even when a catch-up INCRBY skips past it
+	crossed := func(threshold int64) bool { return count-incr < threshold && count >= threshold }
+	switch {
+	case crossed(dailyUpstreamLimit):
+		alert("quota_exhausted", "daily upstream limit of %d reached, serving cached data only until midnight UTC", dailyUpstreamLimit)
+	case crossed((dailyUpstreamLimit*quotaWarnPercent + 99) / 100):
+		alert("quota_warning", "%d of %d daily upstream requests used", count, dailyUpstreamLimit)
+	}
+	if count > dailyUpstreamLimit {
+		return errDailyLimit
+	}
+	return nil
+}
+
+func getUsage(c *gin.Context) {
+	var count int64
+	raw, err := redisGet(c.Request.Context(), usageKey(time.Now()))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to read usage counter"})
+		return
+	}
+	if raw != "" {
+		count, _ = strconv.ParseInt(raw, 10, 64)
+	}
+	if dailyUpstreamLimit > 0 && count > dailyUpstreamLimit {
+		count = dailyUpstreamLimit // rejected attempts are counted too
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"date":    time.Now().UTC().Format("2006-01-02"),
+		"used":    count,
+		"limit":   dailyUpstreamLimit, // 0 = unlimited
+		"resetIn": untilMidnightUTC().Truncate(time.Second).String(),
+	})
+}
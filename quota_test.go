@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func withDailyLimit(t *testing.T, n int64) {
+	old := dailyUpstreamLimit
+	dailyUpstreamLimit = n
+	resetLocalUsage()
+	t.Cleanup(func() {
+		dailyUpstreamLimit = old
+		resetLocalUsage()
+	})
+}
+
+func resetLocalUsage() {
+	localUsage.Lock()
+	localUsage.day, localUsage.count, localUsage.unsynced = "", 0, 0
+	localUsage.Unlock()
+}
+
+func TestDailyLimitHoldsWhileRedisIsThrottled(t *testing.T) {
+	f := useFakeUpstash(t)
+	withDailyLimit(t, 3)
+	throttled, _ := throttlingUpstash(f, "")
+	throttled.Store(true)
+	calls := fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	// The cache is off too, so repeats of one city each go upstream
+	for i := 1; i <= 3; i++ {
+		if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i, w.Code)
+		}
+	}
+	if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("request over the limit without Redis: status = %d, want 503", w.Code)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("upstream calls = %d, want the limit of 3 to hold", n)
+	}
+}
+
+func TestLocalUsageSyncsToRedis(t *testing.T) {
+	f := useFakeUpstash(t)
+	withDailyLimit(t, 10)
+	throttled, _ := throttlingUpstash(f, "")
+	fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	serve(r, "GET", "/weather/London", nil)
+	throttled.Store(true)
+	serve(r, "GET", "/weather/Paris", nil)
+	serve(r, "GET", "/weather/Berlin", nil)
+
+	throttled.Store(false)
+	redisThrottledUntil.Store(0)
+	serve(r, "GET", "/weather/Rome", nil)
+	if v, _ := f.get(usageKey(time.Now())); v != "4" {
+		t.Errorf("Redis counter = %q, want 4 including the calls made while it was throttled", v)
+	}
+}
+
+func TestDailyLimitStopsUpstreamCalls(t *testing.T) {
+	useFakeUpstash(t)
+	withDailyLimit(t, 2)
+	calls := fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	for _, city := range []string{"London", "Paris"} {
+		if w := serve(r, "GET", "/weather/"+city, nil); w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", city, w.Code)
+		}
+	}
+
+	// The budget is spent: misses are refused, cached cities still work
+	w := serve(r, "GET", "/weather/Berlin", nil)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("miss over the limit: status = %d, Retry-After %q; want 503 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusOK {
+		t.Errorf("cached city over the limit: status = %d", w.Code)
+	}
+	// Data older than max_age still beats nothing
+	cacheAged(t, cacheKey("Rome"), 2*time.Hour)
+	if w := serve(r, "GET", "/weather/Rome?max_age=60", nil); w.Code != http.StatusOK {
+		t.Errorf("stale city over the limit: status = %d", w.Code)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream calls = %d, want 2", n)
+	}
+
+	w = serve(r, "GET", "/usage", nil)
+	var usage struct {
+		Used  int64 `json:"used"`
+		Limit int64 `json:"limit"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil || usage.Used != 2 || usage.Limit != 2 {
+		t.Errorf("/usage = %s, want used 2 of 2", w.Body)
+	}
+}
+
+func TestUsageIsCountedWithoutALimit(t *testing.T) {
+	useFakeUpstash(t)
+	withDailyLimit(t, 0)
+	fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	for _, city := range []string{"London", "Paris", "London"} {
+		serve(r, "GET", "/weather/"+city, nil)
+	}
+	w := serve(r, "GET", "/usage", nil)
+	var usage struct {
+		Used  int64 `json:"used"`
+		Limit int64 `json:"limit"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil || usage.Used != 2 || usage.Limit != 0 {
+		t.Errorf("/usage = %s, want used 2 with no limit", w.Body)
+	}
+}
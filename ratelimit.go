@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ulule/limiter/v3"
+	ginlimiter "github.com/ulule/limiter/v3/drivers/middleware/gin"
+	memory "github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+// errRateLimited is returned by fetchLocation for a throttled client whose
+// request isn't already cached.
+var errRateLimited = errors.New("rate limit exceeded")
+
+type cacheOnlyKey struct{}
+
+// cacheOnly reports whether ctx belongs to a throttled request that may
+// only be answered from the cache.
+func cacheOnly(ctx context.Context) bool {
+	v, _ := ctx.Value(cacheOnlyKey{}).(bool)
+	return v
+}
+
+// serveStaleWhenLimited is the RATE_LIMIT_SERVE_STALE alternative to
+// rateLimitReached: throttled GETs on the weather routes still run, but
+// only cached data (of any age) is served and nothing goes upstream, so a
+// miss becomes the usual 429. Streams and everything else are rejected
+// outright since they would otherwise run unthrottled.
+func serveStaleWhenLimited(c *gin.Context) {
+	if c.Request.Method != http.MethodGet || !strings.HasPrefix(c.FullPath(), "/weather/") ||
+		longLivedRoutes[c.FullPath()] || strings.HasSuffix(c.FullPath(), "/debug") {
+		rateLimitReached(c)
+		return
+	}
+
+	c.Header("Warning", `199 - "rate limit exceeded, serving cached data only"`)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), cacheOnlyKey{}, true))
+	c.Next()
+}
+
+// parseIPNets parses a comma-separated list of CIDRs or bare IPs (treated as
+// a single-address range), panicking on garbage so a typo is caught at
+// startup.
+func parseIPNets(name, raw string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			if ip := net.ParseIP(part); ip != nil && ip.To4() != nil {
+				part += "/32"
+			} else {
+				part += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			panic("Invalid " + name + ": " + part)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// rateLimiter builds the per-IP rate limit middleware: 10 req per minute by
+// default, override with RATE_LIMIT (limiter format, e.g. "100-H"). It sets
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset on every
+// response, not just on 429s, so clients can throttle themselves. Clients in
+// RATE_LIMIT_EXEMPT_IPS have no limit and so get no X-RateLimit-* headers.
+func rateLimiter() gin.HandlerFunc {
+	rateLimit := os.Getenv("RATE_LIMIT")
+	if rateLimit == "" {
+		rateLimit = "10-M"
+	}
+	rate, err := limiter.NewRateFromFormatted(rateLimit)
+	if err != nil {
+		panic("Invalid RATE_LIMIT: " + err.Error())
+	}
+	// RATE_LIMIT_SERVE_STALE=true keeps throttled clients going on whatever
+	// is already cached instead of rejecting them outright.
+	limitReached := rateLimitReached
+	if os.Getenv("RATE_LIMIT_SERVE_STALE") == "true" {
+		limitReached = serveStaleWhenLimited
+	}
+	exempt := parseIPNets("RATE_LIMIT_EXEMPT_IPS", os.Getenv("RATE_LIMIT_EXEMPT_IPS"))
+	return ginlimiter.NewMiddleware(limiter.New(memory.NewStore(), rate),
+		ginlimiter.WithLimitReachedHandler(limitReached),
+		ginlimiter.WithExcludedKey(rateLimitExempt(exempt)),
+	)
+}
+
+// rateLimitExempt returns the limiter's excluded-key check for the
+// RATE_LIMIT_EXEMPT_IPS allowlist. The limiter keys on c.ClientIP(), which
+// only trusts X-Forwarded-For from TRUSTED_PROXIES, so a client can't spoof
+// its way onto the list.
+func rateLimitExempt(nets []*net.IPNet) func(string) bool {
+	return func(clientIP string) bool {
+		ip := net.ParseIP(clientIP)
+		if ip == nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func limitedRouter(t *testing.T, rate string) *gin.Engine {
+	t.Setenv("RATE_LIMIT", rate)
+	r := gin.New()
+	r.Use(rateLimiter())
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	return r
+}
+
+func TestRateLimitHeadersDecrement(t *testing.T) {
+	r := limitedRouter(t, "3-M")
+
+	for want := 2; want >= 0; want-- {
+		w := serve(r, "GET", "/ping", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Errorf("X-RateLimit-Limit = %q, want 3", got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != strconv.Itoa(want) {
+			t.Errorf("X-RateLimit-Remaining = %q, want %d", got, want)
+		}
+		if w.Header().Get("X-RateLimit-Reset") == "" {
+			t.Error("X-RateLimit-Reset missing")
+		}
+	}
+
+	w := serve(r, "GET", "/ping", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q on 429, want 0", got)
+	}
+}
+
+func TestRateLimitExemptIPs(t *testing.T) {
+	t.Setenv("RATE_LIMIT_EXEMPT_IPS", "10.0.0.0/8, 192.0.2.7")
+	r := limitedRouter(t, "2-M")
+
+	from := func(addr string) int {
+		req := httptest.NewRequest("GET", "/ping", nil)
+		req.RemoteAddr = addr + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if addr != "198.51.100.1" && w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Errorf("exempt %s got X-RateLimit headers", addr)
+		}
+		return w.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		for _, ip := range []string{"10.1.2.3", "192.0.2.7"} {
+			if code := from(ip); code != http.StatusOK {
+				t.Fatalf("exempt %s request %d: status = %d", ip, i+1, code)
+			}
+		}
+	}
+
+	codes := []int{from("198.51.100.1"), from("198.51.100.1"), from("198.51.100.1")}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("non-exempt statuses = %v, want 200 200 429", codes)
+	}
+}
+
+func TestRateLimitExemptIgnoresUntrustedForwardedFor(t *testing.T) {
+	t.Setenv("RATE_LIMIT_EXEMPT_IPS", "10.0.0.0/8")
+	r := limitedRouter(t, "1-M")
+	r.SetTrustedProxies(nil)
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := serve(r, "GET", "/ping", http.Header{"X-Forwarded-For": {"10.1.2.3"}})
+		if w.Code != want {
+			t.Errorf("request %d: status = %d, want %d", i+1, w.Code, want)
+		}
+	}
+}
+
+func TestServeStaleWhenLimited(t *testing.T) {
+	useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(londonPayload))
+	t.Setenv("RATE_LIMIT", "1-M")
+	t.Setenv("RATE_LIMIT_SERVE_STALE", "true")
+	r := testRouter(t)
+
+	if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusOK || w.Header().Get("Warning") != "" {
+		t.Fatalf("first request: status = %d, Warning %q", w.Code, w.Header().Get("Warning"))
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		// Even data past max_age is served once throttled
+		w := serve(r, "GET", "/weather/London?max_age=60", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		if !strings.Contains(w.Header().Get("Warning"), "rate limit exceeded") {
+			t.Errorf("Warning = %q", w.Header().Get("Warning"))
+		}
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		w := serve(r, "GET", "/weather/Paris", nil)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("status = %d, want 429", w.Code)
+		}
+		if w.Header().Get("Warning") != "" {
+			t.Errorf("429 kept Warning %q", w.Header().Get("Warning"))
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		if w := serve(r, "GET", "/weather/London/stream", nil); w.Code != http.StatusTooManyRequests {
+			t.Errorf("status = %d, want 429", w.Code)
+		}
+	})
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream calls = %d, want only the first request's", n)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// responseOptions are the per-request knobs that shape a typed response.
+type responseOptions struct {
+	Format    string // key into responseBuilders
+	Pretty    bool   // ?pretty=true
+	Precision int    // ?precision=, -1 leaves numbers untouched
+	Case      string // ?case=, JSON only; empty keeps the struct tags
+	Units     unitOptions
+}
+
+// ResponseBuilder turns a response value into bytes for one wire format.
+// Adding a format means registering a builder, not touching handlers.
+type ResponseBuilder interface {
+	Build(v interface{}, opts responseOptions) (body []byte, contentType string, err error)
+}
+
+var responseBuilders = map[string]ResponseBuilder{
+	"json":    jsonBuilder{},
+	"xml":     xmlBuilder{},
+	"msgpack": msgpackBuilder{},
+}
+
+const mimeMsgpack = "application/msgpack"
+
+// unitConverter is implemented by typed responses whose numbers can be
+// converted to the client's units.
+type unitConverter interface {
+	convertUnits(u unitOptions)
+}
+
+// formatOffers maps the media types we can produce to their builder, in
+// order of preference when the client rates them equally.
+var formatOffers = []struct{ mime, format string }{
+	{gin.MIMEJSON, "json"},
+	{gin.MIMEXML, "xml"},
+	{gin.MIMEXML2, "xml"},
+	{mimeMsgpack, "msgpack"},
+	{"application/x-msgpack", "msgpack"},
+}
+
+// responseFormat picks the encoding the client asked for: an explicit
+// ?format= wins, otherwise the offer the Accept header rates highest,
+// defaulting to JSON.
+func responseFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	addVary(c, "Accept")
+
+	accept := c.GetHeader("Accept")
+	format, best := "json", 0.0
+	for _, offer := range formatOffers {
+		if q := acceptQuality(accept, offer.mime); q > best {
+			format, best = offer.format, q
+		}
+	}
+	return format
+}
+
+// acceptQuality returns the q-value an Accept header such as
+// "text/html,application/xml;q=0.9,*/*;q=0.8" gives mime, taken from its
+// most specific matching range. A missing header accepts everything.
+func acceptQuality(header, mime string) float64 {
+	if strings.TrimSpace(header) == "" {
+		return 1
+	}
+	typ, _, _ := strings.Cut(mime, "/")
+
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		var s int
+		switch strings.ToLower(strings.TrimSpace(fields[0])) {
+		case mime:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s < specificity {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, _ = strconv.ParseFloat(param[2:], 64); q < 0 {
+					q = 0
+				}
+			}
+		}
+		if s > specificity || q > quality {
+			quality, specificity = q, s
+		}
+	}
+	return quality
+}
+
+// acceptsJSON reports whether a JSON-only endpoint may answer: the client
+// didn't ask for another ?format= and its Accept header allows JSON, either
+// by name or through a wildcard.
+func acceptsJSON(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return format == "json"
+	}
+	addVary(c, "Accept")
+	return acceptQuality(c.GetHeader("Accept"), gin.MIMEJSON) > 0
+}
+
+// jsonOnly 406s requests acceptsJSON rejects before the handler runs, so
+// JSON-only endpoints that fetch weather don't spend an upstream call on a
+// response they won't send.
+func jsonOnly(c *gin.Context) {
+	if !acceptsJSON(c) {
+		notAcceptable(c)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func notAcceptable(c *gin.Context) {
+	c.JSON(http.StatusNotAcceptable, gin.H{"error": "this endpoint only returns JSON"})
+}
+
+// errUnsupportedFormat is returned by parseResponseOptions for a format no
+// builder is registered for; handlers answer it with a 406.
+var errUnsupportedFormat = errors.New("unsupported response format")
+
+// parseResponseOptions reads every response-shaping query param up front so
+// handlers can reject bad ones before doing any fetching.
+func parseResponseOptions(c *gin.Context) (responseOptions, error) {
+	opts := responseOptions{Format: responseFormat(c), Pretty: c.Query("pretty") == "true"}
+	if _, ok := responseBuilders[opts.Format]; !ok {
+		return opts, errUnsupportedFormat
+	}
+
+	var err error
+	if opts.Precision, err = parsePrecision(c); err != nil {
+		return opts, err
+	}
+	if opts.Units, err = parseUnitOptions(c); err != nil {
+		return opts, err
+	}
+	if opts.Case, err = parseKeyCase(c); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// badResponseOptions answers a parseResponseOptions error.
+func badResponseOptions(c *gin.Context, err error) {
+	if errors.Is(err, errUnsupportedFormat) {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "unsupported format, use one of json, xml, msgpack"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// render converts units and rounds v as requested, then encodes it with the
+// builder for opts.Format. v should be a pointer so the transformations can
+// modify it in place.
+func render(c *gin.Context, status int, v interface{}, opts responseOptions) {
+	if conv, ok := v.(unitConverter); ok {
+		conv.convertUnits(opts.Units)
+	}
+	roundFloats(v, opts.Precision)
+
+	body, contentType, err := responseBuilders[opts.Format].Build(v, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response"})
+		return
+	}
+	if status < 300 {
+		setCacheControl(c)
+	}
+	c.Data(status, contentType, body)
+}
+
+// respond writes v as JSON, indented when the client asks for ?pretty=true.
+// Compact output stays the default since it is what API clients want.
+// Endpoints using respond only speak JSON, so a client that rules JSON out
+// gets a 406; routes that fetch first check with jsonOnly.
+func respond(c *gin.Context, status int, v interface{}) {
+	if !acceptsJSON(c) {
+		notAcceptable(c)
+		return
+	}
+	render(c, status, v, responseOptions{Format: "json", Pretty: c.Query("pretty") == "true", Precision: -1})
+}
+
+type jsonBuilder struct{}
+
+func (jsonBuilder) Build(v interface{}, opts responseOptions) ([]byte, string, error) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, "", err
+	}
+	if opts.Case != "" {
+		if out, err = transformKeys(out, keyCases[opts.Case]); err != nil {
+			return nil, "", err
+		}
+	}
+	if opts.Pretty {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, out, "", "    "); err != nil {
+			return nil, "", err
+		}
+		out = buf.Bytes()
+	}
+	return out, gin.MIMEJSON + "; charset=utf-8", nil
+}
+
+type xmlBuilder struct{}
+
+func (xmlBuilder) Build(v interface{}, opts responseOptions) ([]byte, string, error) {
+	var out []byte
+	var err error
+	if opts.Pretty {
+		out, err = xml.MarshalIndent(v, "", "    ")
+		out = append([]byte(xml.Header), out...)
+	} else {
+		out, err = xml.Marshal(v)
+	}
+	return out, gin.MIMEXML + "; charset=utf-8", err
+}
+
+// msgpackBuilder encodes with the json tags, so field names, omitempty and
+// skipped fields match the JSON output exactly.
+type msgpackBuilder struct{}
+
+func (msgpackBuilder) Build(v interface{}, opts responseOptions) ([]byte, string, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mimeMsgpack, nil
+}
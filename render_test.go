@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// renderRouter serves v on /v through render with the parsed options, like
+// the typed endpoints do.
+func renderRouter(v func() interface{}) *gin.Engine {
+	r := gin.New()
+	r.GET("/v", func(c *gin.Context) {
+		opts, err := parseResponseOptions(c)
+		if err != nil {
+			badResponseOptions(c, err)
+			return
+		}
+		render(c, http.StatusOK, v(), opts)
+	})
+	return r
+}
+
+func TestPrettyPrintIsLongerButEqual(t *testing.T) {
+	r := renderRouter(func() interface{} {
+		return &CurrentResponse{Location: "London", Source: "visual-crossing", Current: CurrentConditions{Conditions: "Clear"}}
+	})
+
+	compact := serve(r, "GET", "/v", nil)
+	pretty := serve(r, "GET", "/v?pretty=true", nil)
+	if compact.Code != http.StatusOK || pretty.Code != http.StatusOK {
+		t.Fatalf("status = %d / %d", compact.Code, pretty.Code)
+	}
+	if pretty.Body.Len() <= compact.Body.Len() {
+		t.Errorf("pretty body is %d bytes, compact %d; want pretty longer", pretty.Body.Len(), compact.Body.Len())
+	}
+	if bytes.Contains(compact.Body.Bytes(), []byte("\n")) {
+		t.Error("compact output contains newlines")
+	}
+
+	var a, b bytes.Buffer
+	json.Compact(&a, compact.Body.Bytes())
+	json.Compact(&b, pretty.Body.Bytes())
+	if a.String() != b.String() {
+		t.Errorf("pretty output differs from compact:\n%s\n%s", a.String(), b.String())
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	temp, wind := 18.5, 12.0
+	want := CurrentResponse{
+		Location: "London, England, United Kingdom",
+		Timezone: "Europe/London",
+		Current: CurrentConditions{
+			Datetime:   "12:00:00",
+			Temp:       &temp,
+			WindSpeed:  &wind,
+			PrecipType: []string{"rain", "snow"},
+			Conditions: "Rain, Overcast",
+			IsDaytime:  true,
+		},
+		Source: "visual-crossing",
+	}
+	r := renderRouter(func() interface{} { v := want; return &v })
+
+	for _, target := range []string{"/v?format=xml", "/v?format=xml&pretty=true"} {
+		w := serve(r, "GET", target, nil)
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, gin.MIMEXML) {
+			t.Fatalf("%s: Content-Type = %q", target, ct)
+		}
+		var got CurrentResponse
+		if err := xml.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v\n%s", target, err, w.Body)
+		}
+		got.XMLName = xml.Name{}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: round trip = %+v, want %+v", target, got, want)
+		}
+	}
+}
+
+// TestMsgpackRoundTrip checks MessagePack carries the same fields and values
+// as JSON, since both are encoded from the json tags.
+func TestMsgpackRoundTrip(t *testing.T) {
+	temp, wind := 18.5, 12.0
+	want := CurrentResponse{
+		Location: "London, England, United Kingdom",
+		Timezone: "Europe/London",
+		Current: CurrentConditions{
+			Datetime:   "12:00:00",
+			Temp:       &temp,
+			WindSpeed:  &wind,
+			PrecipType: []string{"rain", "snow"},
+			Conditions: "Rain, Overcast",
+			IsDaytime:  true,
+		},
+		Source: "visual-crossing",
+	}
+	r := renderRouter(func() interface{} { v := want; return &v })
+
+	asJSON := serve(r, "GET", "/v", nil)
+	for _, req := range []struct {
+		target string
+		header http.Header
+	}{
+		{"/v?format=msgpack", nil},
+		{"/v", http.Header{"Accept": {mimeMsgpack}}},
+		{"/v", http.Header{"Accept": {"application/x-msgpack"}}},
+	} {
+		w := serve(r, "GET", req.target, req.header)
+		if ct := w.Header().Get("Content-Type"); ct != mimeMsgpack {
+			t.Fatalf("%s %v: Content-Type = %q", req.target, req.header, ct)
+		}
+
+		var got CurrentResponse
+		dec := msgpack.NewDecoder(bytes.NewReader(w.Body.Bytes()))
+		dec.SetCustomStructTag("json")
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("%s %v: %v", req.target, req.header, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s %v: round trip = %+v, want %+v", req.target, req.header, got, want)
+		}
+
+		var generic map[string]interface{}
+		if err := msgpack.Unmarshal(w.Body.Bytes(), &generic); err != nil {
+			t.Fatal(err)
+		}
+		var fromJSON map[string]interface{}
+		json.Unmarshal(asJSON.Body.Bytes(), &fromJSON)
+		if len(generic) != len(fromJSON) {
+			t.Errorf("msgpack has %d top-level keys, JSON %d", len(generic), len(fromJSON))
+		}
+		for k := range fromJSON {
+			if _, ok := generic[k]; !ok {
+				t.Errorf("msgpack output is missing JSON key %q", k)
+			}
+		}
+	}
+}
+
+func TestResponseFormatHonoursQuality(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "json"},
+		{"*/*", "json"},
+		{"application/xml", "xml"},
+		{"application/json;q=0.5, application/xml", "xml"},
+		{"application/xml;q=0.5, application/json", "json"},
+		{"application/msgpack", "msgpack"},
+		{"application/*;q=0.9, application/xml;q=0.1", "json"},
+		{"text/csv", "json"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.Header.Set("Accept", tt.accept)
+		if got := responseFormat(c); got != tt.want {
+			t.Errorf("Accept %q: format = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+func TestJSONOnlyEndpointsAcceptBrowsers(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(`{"resolvedAddress":"London","days":[{}]}`))
+	r := testRouter(t)
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   int
+	}{
+		{"browser", "/weather/London", browserAccept, http.StatusOK},
+		{"no header", "/weather/London", "", http.StatusOK},
+		{"json", "/weather/London", "application/json", http.StatusOK},
+		{"xml only", "/weather/London", "application/xml", http.StatusNotAcceptable},
+		{"json excluded", "/weather/London", "application/xml, application/json;q=0", http.StatusNotAcceptable},
+		{"explicit format", "/weather/London?format=xml", browserAccept, http.StatusNotAcceptable},
+		{"status from browser", "/status", browserAccept, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, "GET", tt.target, http.Header{"Accept": {tt.accept}})
+			// /status may report a dependency as down; only the 406 matters
+			if w.Code != tt.want && !(tt.target == "/status" && w.Code == http.StatusServiceUnavailable) {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestJSONOnlyRejectsBeforeFetching(t *testing.T) {
+	useFakeUpstash(t)
+	calls := fakeUpstream(t, servePayload(`{"resolvedAddress":"London","days":[{}]}`))
+	r := testRouter(t)
+
+	w := serve(r, "GET", "/weather/London", http.Header{"Accept": {"application/xml"}})
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("status = %d, want 406", w.Code)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("upstream calls = %d, want 0", n)
+	}
+}
+
+// upperBuilder is a stand-in for a new wire format.
+type upperBuilder struct{}
+
+func (upperBuilder) Build(v interface{}, opts responseOptions) ([]byte, string, error) {
+	out, err := json.Marshal(v)
+	return bytes.ToUpper(out), "text/x-upper", err
+}
+
+func TestRegisteredBuilderServesItsFormat(t *testing.T) {
+	responseBuilders["upper"] = upperBuilder{}
+	t.Cleanup(func() { delete(responseBuilders, "upper") })
+	r := renderRouter(func() interface{} { return &CurrentResponse{Location: "London", Source: "test"} })
+
+	w := serve(r, "GET", "/v?format=upper", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/x-upper" {
+		t.Fatalf("status = %d, Content-Type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `"LOCATION":"LONDON"`) {
+		t.Errorf("body = %s", w.Body)
+	}
+
+	if w := serve(r, "GET", "/v?format=yaml", nil); w.Code != http.StatusNotAcceptable {
+		t.Errorf("unregistered format: status = %d, want 406", w.Code)
+	}
+}
+
+func TestJSONBuilderOptions(t *testing.T) {
+	v := &CurrentResponse{Location: "London", Current: CurrentConditions{DatetimeEpoch: 1700000000, FeelsLikeSource: "computed"}}
+
+	tests := []struct {
+		name string
+		opts responseOptions
+		want []string
+	}{
+		{"tags as written", responseOptions{Precision: -1}, []string{`"datetimeEpoch":1700000000`, `"feelslikeSource":"computed"`}},
+		{"snake case", responseOptions{Case: "snake", Precision: -1}, []string{`"datetime_epoch":1700000000`, `"is_daytime":false`}},
+		{"snake and pretty", responseOptions{Case: "snake", Pretty: true, Precision: -1}, []string{"\n    \"location\": \"London\"", `"datetime_epoch": 1700000000`}},
+	}
+	for _, tt := range tests {
+		body, contentType, err := jsonBuilder{}.Build(v, tt.opts)
+		if err != nil || !strings.HasPrefix(contentType, gin.MIMEJSON) {
+			t.Fatalf("%s: %v, %q", tt.name, err, contentType)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(body), want) {
+				t.Errorf("%s: %s does not contain %s", tt.name, body, want)
+			}
+		}
+	}
+}
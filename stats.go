@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cacheStats counts cache lookups and upstream fetches since startup. The
+// counters are updated from concurrent requests, hence the atomics.
+var cacheStats struct {
+	startedAt     time.Time
+	hits          atomic.Int64
+	misses        atomic.Int64
+	upstreamCalls atomic.Int64
+	upstreamNanos atomic.Int64
+	coalesced     atomic.Int64 // misses answered by another instance's fill
+	initiated     atomic.Int64 // misses that fetched upstream themselves
+}
+
+func init() {
+	cacheStats.startedAt = time.Now()
+}
+
+func recordCacheLookup(hit bool) {
+	if hit {
+		cacheStats.hits.Add(1)
+	} else {
+		cacheStats.misses.Add(1)
+	}
+}
+
+func recordUpstreamCall(elapsed time.Duration) {
+	cacheStats.upstreamCalls.Add(1)
+	cacheStats.upstreamNanos.Add(int64(elapsed))
+}
+
+// recordFill counts how a cache miss was filled: coalesced onto a fetch
+// already in flight under the fill lock, or by initiating our own.
+func recordFill(coalesced bool) {
+	if coalesced {
+		cacheStats.coalesced.Add(1)
+	} else {
+		cacheStats.initiated.Add(1)
+	}
+}
+
+// CacheStatsReport is the body of GET /admin/cache/stats.
+type CacheStatsReport struct {
+	Since                time.Time `json:"since"`
+	TotalRequests        int64     `json:"totalRequests"`
+	Hits                 int64     `json:"hits"`
+	Misses               int64     `json:"misses"`
+	HitRatio             float64   `json:"hitRatio"`
+	UpstreamCalls        int64     `json:"upstreamCalls"`
+	AvgUpstreamLatencyMs float64   `json:"avgUpstreamLatencyMs"`
+	FetchesCoalesced     int64     `json:"fetchesCoalesced"`
+	FetchesInitiated     int64     `json:"fetchesInitiated"`
+}
+
+func currentCacheStats() CacheStatsReport {
+	r := CacheStatsReport{
+		Since:            cacheStats.startedAt,
+		Hits:             cacheStats.hits.Load(),
+		Misses:           cacheStats.misses.Load(),
+		UpstreamCalls:    cacheStats.upstreamCalls.Load(),
+		FetchesCoalesced: cacheStats.coalesced.Load(),
+		FetchesInitiated: cacheStats.initiated.Load(),
+	}
+	r.TotalRequests = r.Hits + r.Misses
+	if r.TotalRequests > 0 {
+		r.HitRatio = float64(r.Hits) / float64(r.TotalRequests)
+	}
+	if r.UpstreamCalls > 0 {
+		avg := time.Duration(cacheStats.upstreamNanos.Load() / r.UpstreamCalls)
+		r.AvgUpstreamLatencyMs = float64(avg) / float64(time.Millisecond)
+	}
+	return r
+}
+
+func getCacheStats(c *gin.Context) {
+	respond(c, http.StatusOK, currentCacheStats())
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoalescedCounterCountsSharedFetches(t *testing.T) {
+	useFakeUpstash(t)
+	calls := fakeUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		servePayload(londonPayload)(w, r)
+	})
+	withAdminToken(t, "secret")
+	r := testRouter(t)
+	before := currentCacheStats()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := serve(r, "GET", "/weather/London", nil); w.Code != http.StatusOK {
+				t.Errorf("status = %d", w.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	after := currentCacheStats()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("upstream calls = %d, want 1", n)
+	}
+	if got := after.FetchesInitiated - before.FetchesInitiated; got != 1 {
+		t.Errorf("fetches initiated += %d, want 1", got)
+	}
+	if got := after.FetchesCoalesced - before.FetchesCoalesced; got != 3 {
+		t.Errorf("fetches coalesced += %d, want 3", got)
+	}
+
+	w := serve(r, "GET", "/metrics", nil)
+	if !strings.Contains(w.Body.String(), "weather_fetches_coalesced_total") {
+		t.Error("/metrics lacks the coalesced counter")
+	}
+	w = serve(r, "GET", "/admin/cache/stats", http.Header{"Authorization": {"Bearer secret"}})
+	if !strings.Contains(w.Body.String(), `"fetchesCoalesced"`) {
+		t.Errorf("/admin/cache/stats = %s", w.Body)
+	}
+}
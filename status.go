@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DependencyStatus is the result of probing one dependency.
+type DependencyStatus struct {
+	Name      string    `json:"name"`
+	Up        bool      `json:"up"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs float64   `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// statusCacheTTL is how long probe results are reused, so dashboards
+// polling /status every second don't hammer Redis and the providers.
+const statusCacheTTL = 5 * time.Second
+
+type dependencyProbe struct {
+	name  string
+	probe func(context.Context) error
+}
+
+var dependencyChecks = struct {
+	sync.Mutex
+	results []DependencyStatus
+	at      time.Time
+}{}
+
+// checkDependencies probes Redis and every configured provider
+// concurrently, reusing results younger than statusCacheTTL.
+func checkDependencies(ctx context.Context) []DependencyStatus {
+	dependencyChecks.Lock()
+	defer dependencyChecks.Unlock()
+	if time.Since(dependencyChecks.at) < statusCacheTTL {
+		return dependencyChecks.results
+	}
+
+	probes := []dependencyProbe{
+		{"redis", pingRedis},
+		{primaryProvider.Name(), primaryProvider.Ping},
+	}
+	if fallbackProvider != nil {
+		probes = append(probes, dependencyProbe{fallbackProvider.Name(), fallbackProvider.Ping})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	results := make([]DependencyStatus, len(probes))
+	var wg sync.WaitGroup
+	for i, p := range probes {
+		wg.Add(1)
+		go func(i int, name string, probe func(context.Context) error) {
+			defer wg.Done()
+			start := time.Now()
+			err := probe(ctx)
+			results[i] = DependencyStatus{
+				Name:      name,
+				Up:        err == nil,
+				LatencyMs: float64(time.Since(start)) / float64(time.Millisecond),
+				CheckedAt: start,
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, p.name, p.probe)
+	}
+	wg.Wait()
+
+	dependencyChecks.results, dependencyChecks.at = results, time.Now()
+	return results
+}
+
+func pingRedis(ctx context.Context) error {
+	_, err := redisCommand(ctx, "PING")
+	return err
+}
+
+// getStatus is the operator dashboard: dependency health, the upstream
+// circuit breaker, cache hit ratio and uptime. It is registered ahead of the
+// rate limiter so frequent polling never gets throttled, and answers 503
+// when any dependency is down or the breaker is open.
+func getStatus(c *gin.Context) {
+	deps := checkDependencies(c.Request.Context())
+	breaker := upstreamBreaker.status()
+	stats := currentCacheStats()
+
+	overall, code := "ok", http.StatusOK
+	for _, d := range deps {
+		if !d.Up {
+			overall, code = "degraded", http.StatusServiceUnavailable
+		}
+	}
+	if breaker.State == "open" {
+		overall, code = "degraded", http.StatusServiceUnavailable
+	}
+
+	respond(c, code, gin.H{
+		"status":        overall,
+		"uptimeSeconds": int64(time.Since(stats.Since).Seconds()),
+		"dependencies":  deps,
+		"breaker":       breaker,
+		"cache": gin.H{
+			"hitRatio":      stats.HitRatio,
+			"totalRequests": stats.TotalRequests,
+		},
+	})
+}
+
+// errUpstreamDown is returned by a Ping that got a 5xx.
+var errUpstreamDown = errors.New("upstream returned a server error")
+
+// pingURL reports whether rawURL answers without a server error. Any 2xx-4xx
+// counts as up, which lets us probe endpoints without a valid API key and so
+// without spending quota.
+func pingURL(ctx context.Context, rawURL string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := weatherClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return errUpstreamDown
+	}
+	return nil
+}
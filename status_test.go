@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func resetDependencyChecks() {
+	dependencyChecks.Lock()
+	dependencyChecks.at = time.Time{}
+	dependencyChecks.Unlock()
+}
+
+func TestStatusReportsBreaker(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(londonPayload))
+	r := testRouter(t)
+
+	getBreaker := func() (int, BreakerStatus) {
+		resetDependencyChecks()
+		w := serve(r, "GET", "/status", nil)
+		var body struct {
+			Breaker BreakerStatus `json:"breaker"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("/status body %s: %v", w.Body, err)
+		}
+		return w.Code, body.Breaker
+	}
+
+	if _, s := getBreaker(); s.State != "disabled" {
+		t.Errorf("without a breaker: state = %q, want disabled", s.State)
+	}
+
+	withRecordingNotifier(t)
+	b := withBreaker(t, 2, time.Minute)
+	b.record(errors.New("visual crossing returned 500"))
+	if code, s := getBreaker(); code != http.StatusOK || s.State != "closed" || s.Failures != 1 || s.NextProbe != nil {
+		t.Errorf("after one failure: %d %+v, want 200, closed with 1 failure", code, s)
+	}
+
+	b.record(errors.New("visual crossing returned 500"))
+	code, s := getBreaker()
+	if code != http.StatusServiceUnavailable || s.State != "open" || s.Failures != 2 {
+		t.Fatalf("after opening: %d %+v, want 503, open with 2 failures", code, s)
+	}
+	if s.NextProbe == nil || time.Until(*s.NextProbe) <= 0 || time.Until(*s.NextProbe) > time.Minute {
+		t.Errorf("nextProbe = %v, want within the cooldown", s.NextProbe)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minStreamInterval is the floor for STREAM_INTERVAL. Each update may cost
+// an upstream fetch, so it matches the smallest max_age we honour.
+var minStreamInterval = minMaxAge
+
+// streamWeather pushes current conditions as Server-Sent Events: once right
+// away, then every STREAM_INTERVAL (default 5m). Each update uses the
+// interval as max_age, so it refetches if the cached copy is older than the
+// last event. The loop ends as soon as the client disconnects.
+func streamWeather(c *gin.Context) {
+	interval := envDuration("STREAM_INTERVAL", 5*time.Minute)
+	if interval < minStreamInterval {
+		interval = minStreamInterval
+	}
+	q := weatherQuery{Location: c.Param("city"), MaxAge: interval}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // stop nginx from buffering events
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		sendCurrentEvent(c, q)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sendCurrentEvent(c *gin.Context, q weatherQuery) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	resp, err := currentConditions(ctx, q)
+	if err != nil {
+		c.SSEvent("error", gin.H{"error": "failed to fetch weather data"})
+	} else {
+		c.SSEvent("current", resp)
+	}
+	c.Writer.Flush()
+}
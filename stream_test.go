@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamSendsEventsUntilDisconnect(t *testing.T) {
+	useFakeUpstash(t)
+	fakeUpstream(t, servePayload(`{"resolvedAddress":"London","days":[{}],"currentConditions":{"datetime":"12:00:00","temp":18}}`))
+	old := minStreamInterval
+	minStreamInterval = 10 * time.Millisecond
+	t.Cleanup(func() { minStreamInterval = old })
+	t.Setenv("STREAM_INTERVAL", "50ms")
+
+	done := make(chan struct{})
+	r := gin.New()
+	r.GET("/weather/:city/stream", func(c *gin.Context) {
+		defer close(done)
+		streamWeather(c)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/weather/London/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	events := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for events < 2 && scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event:") && strings.TrimSpace(strings.TrimPrefix(line, "event:")) != "current" {
+			t.Fatalf("unexpected %s", line)
+		}
+		if strings.HasPrefix(line, "data:") {
+			if !strings.Contains(line, `"location":"London"`) {
+				t.Errorf("event data = %s", line)
+			}
+			events++
+		}
+	}
+	if events != 2 {
+		t.Fatalf("read %d events, want 2 (%v)", events, scanner.Err())
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("stream handler still running after the client disconnected")
+	}
+}
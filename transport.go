@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// HTTP clients for the two upstreams. Each gets its own transport so idle
+// connection pools are sized for its traffic instead of sharing
+// http.DefaultTransport, whose 2 idle connections per host cause constant
+// reconnects (and TLS handshakes) under load.
+var (
+	weatherClient = http.DefaultClient
+	redisClient   = http.DefaultClient
+)
+
+// setupHTTPClients builds the tuned clients. Defaults:
+//
+//   - Visual Crossing: 100 idle conns, 10 per host, 90s idle timeout. Only
+//     cache misses reach it, so a modest per-host pool is enough.
+//   - Upstash: 100 idle conns, 50 per host, 90s idle timeout. Every request
+//     hits it at least once, so keep plenty of warm connections around.
+//
+// Override with WEATHER_HTTP_* / REDIS_HTTP_* + MAX_IDLE_CONNS,
+// MAX_IDLE_CONNS_PER_HOST and IDLE_CONN_TIMEOUT (a Go duration).
+func setupHTTPClients() {
+	weatherClient = &http.Client{Transport: newTransport("WEATHER_HTTP_", 100, 10, 90*time.Second)}
+	redisClient = &http.Client{Transport: newTransport("REDIS_HTTP_", 100, 50, 90*time.Second)}
+}
+
+func newTransport(envPrefix string, maxIdle, maxIdlePerHost int, idleTimeout time.Duration) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = envInt(envPrefix+"MAX_IDLE_CONNS", maxIdle)
+	t.MaxIdleConnsPerHost = envInt(envPrefix+"MAX_IDLE_CONNS_PER_HOST", maxIdlePerHost)
+	t.IdleConnTimeout = envDuration(envPrefix+"IDLE_CONN_TIMEOUT", idleTimeout)
+	return t
+}
+
+// envInt reads a non-negative integer env var, panicking on garbage so a typo
+// is caught at startup.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		panic("Invalid " + name + ": " + raw)
+	}
+	return n
+}
+
+// envDuration reads a Go duration env var (e.g. "90s"), panicking on garbage.
+func envDuration(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		panic("Invalid " + name + ": " + raw)
+	}
+	return d
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Per-field unit overrides for the typed endpoints. We always fetch and
+// cache metric data from Visual Crossing and convert here, so a client asking
+// for Fahrenheit and another asking for Celsius share one cached fetch
+// instead of each spending quota on their own unitGroup.
+type unitOptions struct {
+	Temp   string // C or F
+	Wind   string // kph or mph
+	Precip string // mm or in
+}
+
+var (
+	tempUnits   = map[string]func(float64) float64{"C": identity, "F": celsiusToFahrenheit}
+	windUnits   = map[string]func(float64) float64{"kph": identity, "mph": kphToMph}
+	precipUnits = map[string]func(float64) float64{"mm": identity, "in": mmToInches}
+)
+
+// parseUnitOptions reads ?temp_unit=, ?wind_unit= and ?precip_unit=,
+// defaulting to the metric base units. It returns a user-facing error for
+// unknown unit names.
+func parseUnitOptions(c *gin.Context) (unitOptions, error) {
+	u := unitOptions{
+		Temp:   c.DefaultQuery("temp_unit", "C"),
+		Wind:   c.DefaultQuery("wind_unit", "kph"),
+		Precip: c.DefaultQuery("precip_unit", "mm"),
+	}
+	if _, ok := tempUnits[u.Temp]; !ok {
+		return u, errors.New("temp_unit must be C or F")
+	}
+	if _, ok := windUnits[u.Wind]; !ok {
+		return u, errors.New("wind_unit must be kph or mph")
+	}
+	if _, ok := precipUnits[u.Precip]; !ok {
+		return u, errors.New("precip_unit must be mm or in")
+	}
+	return u, nil
+}
+
+// convert applies fn to a possibly-missing reading in place.
+func convert(v *float64, fn func(float64) float64) {
+	if v != nil {
+		*v = fn(*v)
+	}
+}
+
+func (r *CurrentResponse) convertUnits(u unitOptions) {
+	r.Current.convertUnits(u)
+}
+
+func (cc *CurrentConditions) convertUnits(u unitOptions) {
+	convert(cc.Temp, tempUnits[u.Temp])
+	convert(cc.FeelsLike, tempUnits[u.Temp])
+	convert(cc.WindSpeed, windUnits[u.Wind])
+	convert(cc.Precip, precipUnits[u.Precip])
+}
+
+func identity(v float64) float64 { return v }
+
+func celsiusToFahrenheit(c float64) float64 { return c*9/5 + 32 }
+
+func fahrenheitToCelsius(f float64) float64 { return (f - 32) * 5 / 9 }
+
+func kphToMph(kph float64) float64 { return kph / 1.609344 }
+
+func mmToInches(mm float64) float64 { return mm / 25.4 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestUnitConversions(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(float64) float64
+		in   float64
+		want float64
+	}{
+		{"freezing C to F", celsiusToFahrenheit, 0, 32},
+		{"boiling C to F", celsiusToFahrenheit, 100, 212},
+		{"-40 C to F", celsiusToFahrenheit, -40, -40},
+		{"body temp F to C", fahrenheitToCelsius, 98.6, 37},
+		{"kph to mph", kphToMph, 100, 62.137},
+		{"mm to in", mmToInches, 25.4, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.fn(tt.in); math.Abs(got-tt.want) > 0.001 {
+			t.Errorf("%s: %v -> %v, want %v", tt.name, tt.in, got, tt.want)
+		}
+	}
+
+	for _, c := range []float64{-30, 0, 21.5, 45} {
+		if got := fahrenheitToCelsius(celsiusToFahrenheit(c)); math.Abs(got-c) > 1e-9 {
+			t.Errorf("C->F->C round trip of %v = %v", c, got)
+		}
+	}
+}
+
+func TestConvertUnitsPerField(t *testing.T) {
+	temp, wind, precip := 20.0, 16.09344, 2.54
+	cc := CurrentConditions{Temp: &temp, WindSpeed: &wind, Precip: &precip}
+	cc.convertUnits(unitOptions{Temp: "C", Wind: "mph", Precip: "in"})
+
+	if *cc.Temp != 20 {
+		t.Errorf("temp = %v, want it left in C", *cc.Temp)
+	}
+	if math.Abs(*cc.WindSpeed-10) > 1e-9 || math.Abs(*cc.Precip-0.1) > 1e-9 {
+		t.Errorf("wind = %v, precip = %v; want 10 mph, 0.1 in", *cc.WindSpeed, *cc.Precip)
+	}
+	if cc.FeelsLike != nil {
+		t.Error("missing feelslike was filled in")
+	}
+}
+
+func TestUnitOptionsValidation(t *testing.T) {
+	r := renderRouter(func() interface{} { return &CurrentResponse{} })
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"?temp_unit=F&wind_unit=mph&precip_unit=in", http.StatusOK},
+		{"?temp_unit=K", http.StatusBadRequest},
+		{"?wind_unit=knots", http.StatusBadRequest},
+		{"?precip_unit=cm", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := serve(r, "GET", "/v"+tt.query, nil); w.Code != tt.want {
+			t.Errorf("%q: status = %d, want %d", tt.query, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validationTTL is how long a resolved location name stays cached. Place
+// names don't move, so this only needs refreshing for upstream renames.
+const validationTTL = 30 * 24 * time.Hour
+
+// ValidationResponse is the body of GET /validate.
+type ValidationResponse struct {
+	Valid    bool   `json:"valid"`
+	Resolved string `json:"resolved,omitempty"`
+}
+
+// validateCity answers whether ?city= resolves, using the smallest timeline
+// request Visual Crossing accepts: current conditions with a single
+// element. Unknown locations are a normal answer here, so they return 200
+// with valid=false rather than the 404 the weather endpoints use.
+func validateCity(c *gin.Context) {
+	city := strings.TrimSpace(c.Query("city"))
+	if city == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "city is required"})
+		return
+	}
+
+	entry, err := fetchLocation(c.Request.Context(), weatherQuery{
+		Location: city,
+		Include:  "current",
+		Elements: "datetime",
+		TTL:      validationTTL,
+	}, cacheKey(city, "validate"))
+	if errors.Is(err, errLocationNotFound) {
+		respond(c, http.StatusOK, ValidationResponse{Valid: false})
+		return
+	}
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+
+	var payload weatherPayload
+	if err := json.Unmarshal(entry.Data, &payload); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "unexpected weather data"})
+		return
+	}
+
+	setDataAge(c, entry.FetchedAt)
+	respond(c, http.StatusOK, ValidationResponse{Valid: true, Resolved: payload.ResolvedAddress})
+}
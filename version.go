@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Schema versioning for the typed endpoints (the raw passthrough is Visual
+// Crossing's schema, not ours).
+//
+// Policy: adding an optional field is backward compatible and keeps the
+// version. Removing or renaming a field, or changing its type or meaning,
+// needs a new version; the previous one keeps being served to clients that
+// ask for it with Accept-Version until it is retired. Responses always carry
+// the version they were rendered with in X-Schema-Version.
+const latestSchemaVersion = "v1"
+
+// schemaVersions lists every version we can still render.
+var schemaVersions = map[string]bool{
+	"v1": true,
+}
+
+// schemaVersion negotiates the Accept-Version request header, defaulting to
+// the latest version, and answers 406 for versions we can't produce. The
+// chosen version is stored in the context under "schemaVersion" for
+// handlers that need to render an older shape.
+func schemaVersion(c *gin.Context) {
+	version := strings.ToLower(strings.TrimSpace(c.GetHeader("Accept-Version")))
+	if version == "" {
+		version = latestSchemaVersion
+	}
+	if !schemaVersions[version] {
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+			"error":     "unsupported schema version " + version,
+			"supported": []string{latestSchemaVersion},
+		})
+		return
+	}
+
+	c.Set("schemaVersion", version)
+	c.Header("X-Schema-Version", version)
+	addVary(c, "Accept-Version")
+	c.Next()
+}
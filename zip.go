@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// postalCodeFormats holds the accepted postal code shapes for the countries
+// we validate strictly. Other countries fall back to genericPostalCode.
+var postalCodeFormats = map[string]*regexp.Regexp{
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+}
+
+var (
+	genericPostalCode = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,9}$`)
+	countryCode       = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// validPostalCode reports whether code looks like a postal code for country.
+// Both arguments are expected to be upper-cased already.
+func validPostalCode(code, country string) bool {
+	if format, ok := postalCodeFormats[country]; ok {
+		return format.MatchString(code)
+	}
+	return genericPostalCode.MatchString(code)
+}
+
+func getWeatherByZip(c *gin.Context) {
+	code := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+	country := strings.ToUpper(c.DefaultQuery("country", "US"))
+
+	if !countryCode.MatchString(country) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "country must be a two-letter ISO code"})
+		return
+	}
+	if !validPostalCode(code, country) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid postal code for " + country})
+		return
+	}
+
+	lang, err := requestLang(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	maxAge, err := parseMaxAge(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	extra, err := parseUpstreamParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keyed separately from city lookups so "10115" the ZIP never shares an
+	// entry with a city of the same name.
+	q := weatherQuery{Location: code + "," + country, Lang: lang, MaxAge: maxAge, Extra: extra}
+	entry, err := fetchLocation(c.Request.Context(), q, cacheKey(code, append([]string{"zip", country}, q.keyOptions()...)...))
+	if err != nil {
+		fetchFailed(c, err)
+		return
+	}
+
+	var parsed map[string]interface{}
+	json.Unmarshal(entry.Data, &parsed)
+	setDataAge(c, entry.FetchedAt)
+	respond(c, http.StatusOK, parsed)
+}